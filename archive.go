@@ -0,0 +1,678 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// Name of the archive entry describing the exported elements
+	ARCHIVE_MANIFEST = ".kurios.json"
+
+	// Directory of the archive holding the cache files of earlier versions
+	ARCHIVE_BLOB_DIR = ".kurios/blobs"
+
+	// Default max size of an imported archive and of its unpacked contents, in bytes
+	DEFAULT_IMPORT_MAX_BYTES = 64 * 1024 * 1024
+)
+
+// Ways to handle the imported elements that already exist
+const (
+	IMPORT_SKIP      = "skip"
+	IMPORT_OVERWRITE = "overwrite"
+	IMPORT_RENAME    = "rename"
+)
+
+// Structure counting what an import did
+type ImportReport struct {
+	added       int
+	overwritten int
+	renamed     int
+	skipped     int
+}
+
+// Pack a file or a directory into a zip archive
+// The archive holds the contents of every file, and a manifest keeping the settings, metadata and history of the elements
+// Only the elements the viewer can see and open are exported, and lock keys are left out
+// @param target: string - The path of the file or directory
+// @param channel: string - The channel used to resolve relative paths
+// @param viewer: *Viewer - Who is exporting
+// @return string - The name of the archive
+// @return []byte - The archive
+// @return error - The error if the element cannot be exported
+func exportArchive(target string, channel string, viewer *Viewer) (string, []byte, error) {
+
+	folder, file, err := resolvePath(target, channel, true, true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// The root directory is exported as its contents
+	name := "export"
+	folders, files := map[string]*Folder{}, map[string]*File{}
+	switch {
+	case file != nil:
+		name = file.name
+		files[file.name] = _exportableFile(file)
+	case folder.mounted != nil:
+		return "", nil, errors.New("Error: The contents of a mount cannot be exported.")
+	case folder == &channelEnvironment(channel).root:
+		exported := _exportableFolder(folder, viewer)
+		folders, files = exported.folders, exported.files
+	default:
+		name = folder.name
+		folders[folder.name] = _exportableFolder(folder, viewer)
+	}
+
+	var output bytes.Buffer
+	writer := zip.NewWriter(&output)
+	blobs := map[string]bool{}
+	elements := []string{}
+
+	for _, folder := range folders {
+		elements = append(elements, _saveElement(folder))
+		if err := _exportFolder(writer, folder, folder.name, blobs); err != nil {
+			return "", nil, err
+		}
+	}
+	for _, file := range files {
+		elements = append(elements, _saveFile(file))
+		if err := _exportFile(writer, file, file.name, blobs); err != nil {
+			return "", nil, err
+		}
+	}
+
+	err = _writeEntry(writer, ARCHIVE_MANIFEST, []byte("{\"struct\": {"+strings.Join(elements, ",")+"}}"), time.Now())
+	if err != nil {
+		return "", nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return "", nil, errors.New("Error: Could not create the archive.")
+	}
+
+	return name + ".zip", output.Bytes(), nil
+}
+
+// Copy the part of a directory a viewer can export
+// Hidden elements the viewer cannot see, locked and unavailable elements are left out with their contents
+// @param folder: *Folder - The directory
+// @param viewer: *Viewer - Who is exporting
+// @return *Folder - The copy, without lock keys
+func _exportableFolder(folder *Folder, viewer *Viewer) *Folder {
+
+	copied := *folder
+	copied.key = ""
+	copied.folders, copied.files = map[string]*Folder{}, map[string]*File{}
+	if folder.mounted != nil {
+		return &copied
+	}
+
+	for _, sub := range visibleFolders(folder, viewer) {
+		if nodeState(sub.availableBetween, sub.locked) == NODE_OK {
+			copied.folders[sub.name] = _exportableFolder(sub, viewer)
+		}
+	}
+	for _, file := range visibleFiles(folder, viewer) {
+		if nodeState(file.availableBetween, file.locked) == NODE_OK {
+			copied.files[file.name] = _exportableFile(file)
+		}
+	}
+
+	return &copied
+}
+
+// Copy a file to export
+// @param file: *File - The file
+// @return *File - The copy, without lock key
+func _exportableFile(file *File) *File {
+	copied := *file
+	copied.key = ""
+	return &copied
+}
+
+// Add a directory and its contents to an archive
+// The contents of mounts stay on the host
+// @param writer: *zip.Writer - The archive
+// @param folder: *Folder - The directory
+// @param entry: string - The name of the directory in the archive
+// @param blobs: map[string]bool - The cache files of earlier versions already added
+// @return error - The error if a file cannot be added
+func _exportFolder(writer *zip.Writer, folder *Folder, entry string, blobs map[string]bool) error {
+
+	if folder.mounted != nil {
+		return nil
+	}
+
+	if err := _writeEntry(writer, entry+"/", nil, folder.meta.modified); err != nil {
+		return err
+	}
+
+	for _, sub := range folder.folders {
+		if err := _exportFolder(writer, sub, entry+"/"+sub.name, blobs); err != nil {
+			return err
+		}
+	}
+	for _, file := range folder.files {
+		if err := _exportFile(writer, file, entry+"/"+file.name, blobs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add a file and the cache files of its earlier versions to an archive
+// Links only live in the manifest
+// @param writer: *zip.Writer - The archive
+// @param file: *File - The file
+// @param entry: string - The name of the file in the archive
+// @param blobs: map[string]bool - The cache files of earlier versions already added
+// @return error - The error if the file cannot be added
+func _exportFile(writer *zip.Writer, file *File, entry string, blobs map[string]bool) error {
+
+	if file.link != "" {
+		return nil
+	}
+
+	data, err := readFile(file)
+	if err != nil {
+		return err
+	}
+	if err := _writeEntry(writer, entry, data, file.meta.modified); err != nil {
+		return err
+	}
+
+	for _, revision := range file.history {
+		if revision.cache == "" || blobs[revision.cache] {
+			continue
+		}
+		blobs[revision.cache] = true
+
+		data, err := loadBlob(revision.cache, "")
+		if err != nil {
+			return err
+		}
+		if err := _writeEntry(writer, ARCHIVE_BLOB_DIR+"/"+revision.cache, data, revision.time); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write an entry of an archive
+// @param writer: *zip.Writer - The archive
+// @param entry: string - The name of the entry, ending with a slash for a directory
+// @param data: []byte - The contents of the entry
+// @param modified: time.Time - When the contents last changed, or zero if unknown
+// @return error - The error if the entry cannot be written
+func _writeEntry(writer *zip.Writer, entry string, data []byte, modified time.Time) error {
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	output, err := writer.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Deflate, Modified: modified})
+	if err == nil {
+		_, err = output.Write(data)
+	}
+	if err != nil {
+		return errors.New("Error: Could not add \"" + entry + "\" to the archive.")
+	}
+	return nil
+}
+
+// Parse the arguments of the import command
+// @param commands: []string - The command line
+// @return string - How to handle the elements that already exist
+// @return []string - The other arguments
+// @return error - The error if the way to handle existing elements is unknown
+func parseImportArgs(commands []string) (string, []string, error) {
+
+	mode := IMPORT_SKIP
+	args := []string{}
+	for i := 1; i < len(commands); i++ {
+		if commands[i] == "-c" && i+1 < len(commands) {
+			mode = commands[i+1]
+			i++
+		} else {
+			args = append(args, commands[i])
+		}
+	}
+
+	if mode != IMPORT_SKIP && mode != IMPORT_OVERWRITE && mode != IMPORT_RENAME {
+		return "", nil, errors.New("Error: Invalid conflict handling \"" + mode + "\". Expecting \"skip\", \"overwrite\" or \"rename\".")
+	}
+
+	return mode, args, nil
+}
+
+// Unpack a zip archive into a directory
+// Archives made by export restore their elements with their settings, other archives are unpacked as plain files
+// @param data: []byte - The archive
+// @param archiveName: string - The name of the archive
+// @param target: string - The path of the directory receiving the elements
+// @param mode: string - How to handle the elements that already exist: skip, overwrite or rename
+// @param channel: string - The channel used to resolve relative paths
+// @param author: string - The user ID of the importer, or empty if unknown
+// @param allowed: func(string) bool - Whether the importer has a permission, for the settings found in the manifest
+// @return ImportReport - What the import did
+// @return error - The error if the archive cannot be imported
+func importArchive(data []byte, archiveName string, target string, mode string, channel string, author string, allowed func(string) bool) (ImportReport, error) {
+
+	report := ImportReport{}
+
+	destination, err := getDirectory(target, true, channel)
+	if err != nil {
+		return report, err
+	}
+	if destination.mounted != nil {
+		return report, errors.New("Error: Archives cannot be imported inside a mount.")
+	}
+
+	entries, err := _readArchive(data, archiveName)
+	if err != nil {
+		return report, err
+	}
+
+	var folders map[string]*Folder
+	var files map[string]*File
+	if manifest, ok := entries[ARCHIVE_MANIFEST]; ok {
+		folders, files, err = _loadManifest(manifest, entries, _childPath(destination), allowed, &report)
+	} else {
+		folders, files, err = _loadEntries(entries, author, archiveName)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	_mergeInto(destination, folders, files, mode, author, "Imported from "+archiveName, &report)
+	return report, nil
+}
+
+// Describe what an import did
+// @param report: ImportReport - What the import did
+// @param archiveName: string - The name of the archive
+// @param destination: string - The path of the directory receiving the elements
+// @return string - The description
+func drawImport(report ImportReport, archiveName string, destination string) string {
+	return fmt.Sprintf("Imported \"%s\" into \"%s\": %d added, %d overwritten, %d renamed, %d skipped.", archiveName, destination, report.added, report.overwritten, report.renamed, report.skipped)
+}
+
+// Read the entries of a zip archive
+// Names are kept inside the archive and must be valid names, and the unpacked size is capped by "import_max_bytes" in the configuration file
+// @param data: []byte - The archive
+// @param archiveName: string - The name of the archive
+// @return map[string][]byte - The contents of the files by name, directories ending with a slash
+// @return error - The error if the archive cannot be read
+func _readArchive(data []byte, archiveName string) (map[string][]byte, error) {
+
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("Error: \"" + archiveName + "\" is not a zip archive.")
+	}
+
+	remaining := int64(configInt("import_max_bytes", DEFAULT_IMPORT_MAX_BYTES))
+	entries := map[string][]byte{}
+	for _, entry := range reader.File {
+
+		name := _cleanEntry(entry.Name)
+		if name == "" {
+			continue
+		}
+		for _, segment := range strings.Split(name, "/") {
+			if err := checkName(segment); err != nil {
+				return nil, errors.New(err.Error() + " Found in \"" + archiveName + "\".")
+			}
+		}
+		if entry.FileInfo().IsDir() {
+			entries[name+"/"] = nil
+			continue
+		}
+
+		contents, err := entry.Open()
+		if err != nil {
+			return nil, errors.New("Error: Could not read \"" + name + "\" in \"" + archiveName + "\".")
+		}
+		unpacked, err := io.ReadAll(io.LimitReader(contents, remaining+1))
+		contents.Close()
+		if err != nil {
+			return nil, errors.New("Error: Could not read \"" + name + "\" in \"" + archiveName + "\".")
+		}
+
+		remaining -= int64(len(unpacked))
+		if remaining < 0 {
+			return nil, fmt.Errorf("Error: The contents of \"%s\" are larger than %d bytes.", archiveName, configInt("import_max_bytes", DEFAULT_IMPORT_MAX_BYTES))
+		}
+		entries[name] = unpacked
+	}
+
+	return entries, nil
+}
+
+// Load the elements described by the manifest of an exported archive
+// Mounts are left out, their contents were not exported
+// @param manifest: []byte - The manifest
+// @param entries: map[string][]byte - The entries of the archive
+// @param base: string - The path of the directory receiving the elements, ending with a slash
+// @param allowed: func(string) bool - Whether the importer has a permission
+// @param report: *ImportReport - Counts the elements left out
+// @return map[string]*Folder - The folders loaded
+// @return map[string]*File - The files loaded
+// @return error - The error if the manifest is invalid
+func _loadManifest(manifest []byte, entries map[string][]byte, base string, allowed func(string) bool, report *ImportReport) (map[string]*Folder, map[string]*File, error) {
+
+	structure := gjson.GetBytes(manifest, "struct")
+	if !gjson.ValidBytes(manifest) || !structure.IsObject() {
+		return nil, nil, errors.New("Error: The manifest of the archive is invalid.")
+	}
+
+	folders, files, err := _loadElement(&structure, base)
+	if err != nil {
+		return nil, nil, errors.New("Error: The manifest of the archive is invalid. " + err.Error())
+	}
+
+	// Store the cache files of earlier versions, which may get a new cache path
+	caches := map[string]string{}
+	for name, data := range entries {
+		if strings.HasPrefix(name, ARCHIVE_BLOB_DIR+"/") && data != nil {
+			cache, _, err := storeBlob(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			caches[strings.TrimPrefix(name, ARCHIVE_BLOB_DIR+"/")] = cache
+		}
+	}
+
+	err = _fillContents(folders, files, "", entries, caches, allowed, report)
+	return folders, files, err
+}
+
+// Give the loaded files the contents found in the archive, and leave out what the importer may not set
+// Archives are not trusted: mounts are left out, hooks and reveal conditions need the "hooks" permission,
+// locks the "lock" permission and links the "ln" permission, and cache paths not found in the archive are dropped
+// @param folders: map[string]*Folder - The folders loaded
+// @param files: map[string]*File - The files loaded
+// @param prefix: string - The name of their directory in the archive, empty for the top
+// @param entries: map[string][]byte - The entries of the archive
+// @param caches: map[string]string - The new cache path of the cache files of earlier versions
+// @param allowed: func(string) bool - Whether the importer has a permission
+// @param report: *ImportReport - Counts the elements left out
+// @return error - The error if the contents cannot be stored
+func _fillContents(folders map[string]*Folder, files map[string]*File, prefix string, entries map[string][]byte, caches map[string]string, allowed func(string) bool, report *ImportReport) error {
+
+	for name, folder := range folders {
+		if err := checkName(name); err != nil {
+			return err
+		}
+		if folder.mounted != nil {
+			delete(folders, name)
+			report.skipped++
+			continue
+		}
+		_restrictImported(&folder.locked, &folder.key, &folder.hooks, &folder.revealWhen, allowed)
+		if err := _fillContents(folder.folders, folder.files, prefix+name+"/", entries, caches, allowed, report); err != nil {
+			return err
+		}
+	}
+
+	for name, file := range files {
+		if err := checkName(name); err != nil {
+			return err
+		}
+		if file.link != "" && !allowed("ln") {
+			delete(files, name)
+			report.skipped++
+			continue
+		}
+		_restrictImported(&file.locked, &file.key, &file.hooks, &file.revealWhen, allowed)
+
+		// Contents only come from the archive
+		file.cache, file.hash = "", ""
+		if data, ok := entries[prefix+name]; ok && file.link == "" {
+			if err := _storeContents(file, data); err != nil {
+				return err
+			}
+		}
+
+		// Earlier versions whose cache file is not in the archive are left out
+		history := []Revision{}
+		for i, revision := range file.history {
+			if revision.cache != "" && i < len(file.history)-1 {
+				cache, ok := caches[revision.cache]
+				if !ok {
+					continue
+				}
+				revision.cache = cache
+			}
+			history = append(history, revision)
+		}
+		file.history = history
+	}
+
+	return nil
+}
+
+// Drop the settings of an imported element the importer may not set
+// @param locked: *bool - Whether the element is locked
+// @param key: *string - The key of the lock
+// @param hooks: *map[string]string - The hooks of the element
+// @param revealWhen: *string - The reveal conditions of the element
+// @param allowed: func(string) bool - Whether the importer has a permission
+func _restrictImported(locked *bool, key *string, hooks *map[string]string, revealWhen *string, allowed func(string) bool) {
+	if !allowed("lock") {
+		*locked, *key = false, ""
+	}
+	if !allowed("hooks") {
+		*hooks, *revealWhen = map[string]string{}, ""
+	}
+}
+
+// Build the elements of a plain archive
+// Their paths are set once they are placed in the tree
+// @param entries: map[string][]byte - The entries of the archive
+// @param author: string - The user ID of the importer, or empty if unknown
+// @param archiveName: string - The name of the archive
+// @return map[string]*Folder - The folders found
+// @return map[string]*File - The files found
+// @return error - The error if the contents cannot be stored
+func _loadEntries(entries map[string][]byte, author string, archiveName string) (map[string]*Folder, map[string]*File, error) {
+
+	top := &Folder{"", "/", map[string]*Folder{}, map[string]*File{}, []string{"*", "*"}, false, "", map[string]string{}, false, "", false, nil, []string{}, Metadata{}}
+
+	for name, data := range entries {
+		if strings.HasPrefix(name, ".kurios/") {
+			continue
+		}
+
+		// Create the directories on the way
+		parent := top
+		segments := strings.Split(strings.TrimSuffix(name, "/"), "/")
+		for _, segment := range segments[:len(segments)-1] {
+			parent = _importedFolder(parent, segment, author)
+		}
+
+		last := segments[len(segments)-1]
+		if strings.HasSuffix(name, "/") {
+			_importedFolder(parent, last, author)
+			continue
+		}
+
+		file := &File{last, _childPath(parent), "", "", []string{"*", "*"}, false, "", map[string]string{}, false, "", false, "", nil, "", newMetadata(author), nil}
+		if err := _storeContents(file, data); err != nil {
+			return nil, nil, err
+		}
+		addRevision(file, author, "Imported from "+archiveName)
+		parent.files[last] = file
+	}
+
+	return top.folders, top.files, nil
+}
+
+// Get a folder of a plain archive, creating it if needed
+// @param parent: *Folder - The directory holding the folder
+// @param name: string - The name of the folder
+// @param author: string - The user ID of the importer, or empty if unknown
+// @return *Folder - The folder
+func _importedFolder(parent *Folder, name string, author string) *Folder {
+
+	if folder, ok := parent.folders[name]; ok {
+		return folder
+	}
+
+	folder := &Folder{name, _childPath(parent), map[string]*Folder{}, map[string]*File{}, []string{"*", "*"}, false, "", map[string]string{}, false, "", false, nil, []string{}, newMetadata(author)}
+	parent.folders[name] = folder
+	return folder
+}
+
+// Set the contents of an imported file
+// Small text files are kept in the environment, other files in the cache directory
+// @param file: *File - The file
+// @param data: []byte - The contents
+// @return error - The error if the contents cannot be stored
+func _storeContents(file *File, data []byte) error {
+
+	if strings.HasPrefix(http.DetectContentType(data), "text/") && utf8.Valid(data) && len(data) <= configInt("upload_inline_bytes", DEFAULT_UPLOAD_INLINE_BYTES) {
+		file.data, file.cache, file.hash = string(data), "", ""
+		return nil
+	}
+
+	cache, hash, err := storeBlob(data)
+	if err != nil {
+		return err
+	}
+	file.data, file.cache, file.hash = "", cache, hash
+	return nil
+}
+
+// Add imported elements to a directory
+// Folders that already exist are merged, other elements that already exist are skipped, overwritten or renamed
+// @param directory: *Folder - The directory receiving the elements
+// @param folders: map[string]*Folder - The imported folders
+// @param files: map[string]*File - The imported files
+// @param mode: string - How to handle the elements that already exist: skip, overwrite or rename
+// @param author: string - The user ID of the importer, or empty if unknown
+// @param message: string - The change kept in the history of the files overwritten
+// @param report: *ImportReport - Counts what the import did
+func _mergeInto(directory *Folder, folders map[string]*Folder, files map[string]*File, mode string, author string, message string, report *ImportReport) {
+
+	expandFolder(directory)
+
+	for name, folder := range folders {
+		existing, isFolder := directory.folders[name]
+		_, isFile := directory.files[name]
+
+		switch {
+		case isFolder && existing.mounted == nil:
+			_mergeInto(existing, folder.folders, folder.files, mode, author, message, report)
+		case !isFolder && !isFile:
+			_placeFolder(directory, name, folder)
+			report.added++
+		case mode == IMPORT_OVERWRITE:
+			delete(directory.folders, name)
+			delete(directory.files, name)
+			_placeFolder(directory, name, folder)
+			report.overwritten++
+		case mode == IMPORT_RENAME:
+			_placeFolder(directory, _freeName(directory, name), folder)
+			report.renamed++
+		default:
+			report.skipped++
+		}
+	}
+
+	for name, file := range files {
+		existing, isFile := directory.files[name]
+		_, isFolder := directory.folders[name]
+
+		switch {
+		case !isFolder && !isFile:
+			_placeFile(directory, name, file)
+			report.added++
+		case mode == IMPORT_OVERWRITE && isFile && existing.link == "" && existing.mounted == nil && file.link == "":
+			// Files overwritten keep their history
+			keepRevision(existing)
+			existing.data, existing.cache, existing.hash = file.data, file.cache, file.hash
+			existing.meta.touch(author)
+			addRevision(existing, author, message)
+			report.overwritten++
+		case mode == IMPORT_OVERWRITE:
+			delete(directory.folders, name)
+			delete(directory.files, name)
+			_placeFile(directory, name, file)
+			report.overwritten++
+		case mode == IMPORT_RENAME:
+			_placeFile(directory, _freeName(directory, name), file)
+			report.renamed++
+		default:
+			report.skipped++
+		}
+	}
+}
+
+// Put an imported folder in a directory, updating the paths of its contents
+// @param directory: *Folder - The directory
+// @param name: string - The name of the folder in the directory
+// @param folder: *Folder - The folder
+func _placeFolder(directory *Folder, name string, folder *Folder) {
+	folder.name, folder.path = name, _childPath(directory)
+	_rebase(folder)
+	directory.folders[name] = folder
+}
+
+// Put an imported file in a directory
+// @param directory: *Folder - The directory
+// @param name: string - The name of the file in the directory
+// @param file: *File - The file
+func _placeFile(directory *Folder, name string, file *File) {
+	file.name, file.path = name, _childPath(directory)
+	directory.files[name] = file
+}
+
+// Update the paths of the contents of a folder after it moved
+// @param folder: *Folder - The folder
+func _rebase(folder *Folder) {
+	for _, sub := range folder.folders {
+		sub.path = _childPath(folder)
+		_rebase(sub)
+	}
+	for _, file := range folder.files {
+		file.path = _childPath(folder)
+	}
+}
+
+// Get the path of the elements of a directory
+// @param directory: *Folder - The directory
+// @return string - The path, ending with a slash
+func _childPath(directory *Folder) string {
+	if directory.name == "" {
+		return "/"
+	}
+	return directory.path + directory.name + "/"
+}
+
+// Find a name that is not used in a directory yet, like "notes (2).txt"
+// @param directory: *Folder - The directory
+// @param name: string - The name wanted
+// @return string - The first free name
+func _freeName(directory *Folder, name string) string {
+
+	extension := path.Ext(name)
+	stem := strings.TrimSuffix(name, extension)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, i, extension)
+		_, isFolder := directory.folders[candidate]
+		_, isFile := directory.files[candidate]
+		if !isFolder && !isFile {
+			return candidate
+		}
+	}
+}
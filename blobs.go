@@ -0,0 +1,260 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Names of the blobs written by the store
+var blobNamePattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Get the directory holding the cache files
+// Set by "cache_dir" in the configuration file
+// @return string - The absolute path of the directory
+func blobRoot() string {
+	dir := DEFAULT_CACHE_DIR
+	if configured, ok := config["cache_dir"].(string); ok && configured != "" {
+		dir = configured
+	}
+	if absolute, err := filepath.Abs(dir); err == nil {
+		return absolute
+	}
+	return dir
+}
+
+// Get the hash identifying some contents
+// @param data: []byte - The contents
+// @return string - The hash, as hexadecimal
+func blobHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Resolve the cache path of a file inside the cache directory
+// Paths are always relative to the cache directory, and cannot leave it
+// @param cache: string - The cache path of the file
+// @return string - The path on the host
+// @return error - The error if the path leaves the cache directory
+func resolveCachePath(cache string) (string, error) {
+
+	path, ok := resolveInside(blobRoot(), cache)
+	if !ok || path == blobRoot() {
+		return "", errors.New("Error: The cache path \"" + cache + "\" is outside of the cache directory.")
+	}
+
+	return path, nil
+}
+
+// Resolve a path inside a directory of the host
+// @param root: string - The absolute path of the directory
+// @param path: string - The path, relative to the directory
+// @return string - The path on the host
+// @return bool - True if the path stays inside the directory, false otherwise
+func resolveInside(root string, path string) (string, bool) {
+
+	resolved := filepath.Join(root, filepath.Clean("/"+filepath.ToSlash(path)))
+
+	relative, err := filepath.Rel(root, resolved)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return resolved, true
+}
+
+// Write contents to the cache directory, named after their hash
+// Identical contents are only stored once
+// @param data: []byte - The contents to store
+// @return string - The cache path of the contents
+// @return string - The hash of the contents
+// @return error - The error if the contents could not be written
+func storeBlob(data []byte) (string, string, error) {
+
+	hash := blobHash(data)
+	cache := hash[:2] + "/" + hash
+
+	path, err := resolveCachePath(cache)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return cache, hash, nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", "", errors.New("Error: Could not create the cache directory.")
+	}
+
+	// Write to a temporary file first so a blob is never seen half written
+	temp := path + ".tmp"
+	err = os.WriteFile(temp, data, 0644)
+	if err == nil {
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		os.Remove(temp)
+		return "", "", errors.New("Error: Could not write the file to the cache directory.")
+	}
+
+	return cache, hash, nil
+}
+
+// Read contents from the cache directory, checking their hash
+// @param cache: string - The cache path of the contents
+// @param hash: string - The expected hash, or empty to skip the check
+// @return []byte - The contents
+// @return error - The error if the contents could not be read or were altered
+func loadBlob(cache string, hash string) ([]byte, error) {
+
+	path, err := resolveCachePath(cache)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("Error: Could not open the file \"" + cache + "\".")
+	}
+
+	if hash != "" && blobHash(data) != hash {
+		return nil, errors.New("Error: The file \"" + cache + "\" does not match its hash.")
+	}
+
+	return data, nil
+}
+
+// Record the hash of every cache file missing one
+// @param folder: *Folder - The folder to explore
+func hashCacheFiles(folder *Folder) {
+	for _, file := range folder.files {
+		if file.cache != "" && file.hash == "" {
+			if data, err := loadBlob(file.cache, ""); err == nil {
+				file.hash = blobHash(data)
+			}
+		}
+	}
+	for _, sub := range folder.folders {
+		hashCacheFiles(sub)
+	}
+}
+
+// Collect the cache paths used by a folder
+// @param folder: *Folder - The folder to explore
+// @param used: map[string]bool - The host paths in use
+func _collectCachePaths(folder *Folder, used map[string]bool) {
+	for _, file := range folder.files {
+		if file.cache != "" {
+			if path, err := resolveCachePath(file.cache); err == nil {
+				used[path] = true
+			}
+		}
+		for _, revision := range file.history {
+			if revision.cache != "" {
+				if path, err := resolveCachePath(revision.cache); err == nil {
+					used[path] = true
+				}
+			}
+		}
+	}
+	for _, sub := range folder.folders {
+		_collectCachePaths(sub, used)
+	}
+}
+
+// Delete the blobs no environment uses anymore
+// Environments in memory and every environment file on disk are kept: the one loaded, the ones of the guilds,
+// the other JSON files next to them, and the files and directories listed by "gc_paths" in the configuration file,
+// such as templates and backups. Nothing is deleted if one of these files cannot be read.
+// Only the files written by the store are collected
+// @return int - The number of blobs deleted
+// @return error - The error if an environment file could not be read
+func collectBlobs() (int, error) {
+
+	used := map[string]bool{}
+	for _, env := range allEnvironments() {
+		_collectCachePaths(&env.root, used)
+	}
+
+	for _, file := range _environmentFiles() {
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil || !gjson.ValidBytes(data) {
+			return 0, errors.New("Error: Could not read \"" + file + "\", no cache file was deleted.")
+		}
+		_collectCacheValues(gjson.ParseBytes(data), used)
+	}
+
+	deleted := 0
+	filepath.Walk(blobRoot(), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !blobNamePattern.MatchString(info.Name()) || used[path] {
+			return nil
+		}
+		if os.Remove(path) == nil {
+			deleted++
+		}
+		return nil
+	})
+
+	return deleted, nil
+}
+
+// List the environment files whose cache files must be kept
+// @return []string - The paths of the files
+func _environmentFiles() []string {
+
+	files := []string{*envPath}
+	if data, err := ioutil.ReadFile(*guildsPath); err == nil {
+		for _, settings := range gjson.ParseBytes(data).Map() {
+			files = append(files, settings.Get("directory").String())
+		}
+	}
+
+	// Backups and templates are often kept next to the environments
+	directories := map[string]bool{filepath.Dir(*envPath): true, filepath.Dir(*guildsPath): true}
+	for _, path := range configStrings("gc_paths", []string{}) {
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && strings.HasSuffix(file, ".json") {
+				files = append(files, file)
+			}
+			return nil
+		})
+	}
+	for directory := range directories {
+		matches, _ := filepath.Glob(filepath.Join(directory, "*.json"))
+		files = append(files, matches...)
+	}
+
+	return files
+}
+
+// Collect the cache paths found anywhere in a JSON document
+// @param value: gjson.Result - The document
+// @param used: map[string]bool - The host paths in use
+func _collectCacheValues(value gjson.Result, used map[string]bool) {
+	value.ForEach(func(key gjson.Result, child gjson.Result) bool {
+		if key.String() == "cache" && child.Type == gjson.String {
+			if path, err := resolveCachePath(child.String()); err == nil {
+				used[path] = true
+			}
+		} else if child.IsObject() || child.IsArray() {
+			_collectCacheValues(child, used)
+		}
+		return true
+	})
+}
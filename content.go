@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// Default max number of bytes shown by a hex dump
+	DEFAULT_HEXDUMP_BYTES = 512
+
+	// Number of bytes on each line of a hex dump
+	HEXDUMP_LINE_BYTES = 16
+)
+
+// Detect the content type of the contents of a file
+// @param data: []byte - The contents
+// @return string - The content type, without its parameters
+func contentType(data []byte) string {
+	return strings.TrimSpace(strings.Split(http.DetectContentType(data), ";")[0])
+}
+
+// Determine if contents cannot be shown as text
+// @param data: []byte - The contents
+// @return bool - True if the contents are binary, false otherwise
+func isBinary(data []byte) bool {
+
+	if !utf8.Valid(data) {
+		return true
+	}
+
+	// Text never holds control characters other than whitespace
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' && b != '\v' && b != 0x1b {
+			return true
+		}
+	}
+	return false
+}
+
+// Determine if contents are an image Discord can show
+// @param data: []byte - The contents
+// @return bool - True if the contents are an image, false otherwise
+func isImage(data []byte) bool {
+	return strings.HasPrefix(contentType(data), "image/")
+}
+
+// Draw contents the way xxd does: offset, hexadecimal bytes and printable characters
+// @param data: []byte - The contents
+// @param limit: int - The max number of bytes to draw, or -1 for every byte
+// @return string - The hex dump
+func hexdump(data []byte, limit int) string {
+
+	shown := data
+	if limit >= 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+
+	output := ""
+	for offset := 0; offset < len(shown); offset += HEXDUMP_LINE_BYTES {
+		end := offset + HEXDUMP_LINE_BYTES
+		if end > len(shown) {
+			end = len(shown)
+		}
+		line := shown[offset:end]
+
+		hexPart := ""
+		for i := 0; i < HEXDUMP_LINE_BYTES; i++ {
+			if i < len(line) {
+				hexPart += fmt.Sprintf("%02x", line[i])
+			} else {
+				hexPart += "  "
+			}
+			if i%2 == 1 {
+				hexPart += " "
+			}
+		}
+
+		textPart := ""
+		for _, b := range line {
+			if b >= 0x20 && b < 0x7f {
+				textPart += string(rune(b))
+			} else {
+				textPart += "."
+			}
+		}
+
+		output += fmt.Sprintf("%08x: %s %s\n", offset, hexPart, textPart)
+	}
+
+	if len(shown) < len(data) {
+		output += fmt.Sprintf("... %d more byte(s)\n", len(data)-len(shown))
+	}
+	return output
+}
+
+// Describe contents that cannot be shown as text, followed by their hex dump
+// The number of bytes shown is set by "hexdump_bytes" in the configuration file
+// @param name: string - The name of the file
+// @param data: []byte - The contents
+// @return string - The description
+func drawBinary(name string, data []byte) string {
+	output := fmt.Sprintf("%s: %s, %d byte(s)\n", name, contentType(data), len(data))
+	return output + hexdump(data, configInt("hexdump_bytes", DEFAULT_HEXDUMP_BYTES))
+}
+
+// Decode contents written in base64 or hexadecimal
+// Whitespace is ignored, so wrapped contents can be decoded
+// @param encoding: string - "base64" or "hex"
+// @param text: string - The encoded contents
+// @return []byte - The decoded contents
+// @return error - The error if the encoding is unknown or the contents are invalid
+func decodeContents(encoding string, text string) ([]byte, error) {
+
+	text = strings.Join(strings.Fields(text), "")
+
+	switch encoding {
+	case "base64":
+		// Accept padded, unpadded and URL safe base64
+		for _, decoder := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if data, err := decoder.DecodeString(text); err == nil {
+				return data, nil
+			}
+		}
+		return nil, errors.New("Error: The contents are not valid base64.")
+
+	case "hex":
+		data, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(text), "0x"))
+		if err != nil {
+			return nil, errors.New("Error: The contents are not valid hexadecimal.")
+		}
+		return data, nil
+	}
+
+	return nil, errors.New("Error: Unknown encoding \"" + encoding + "\". Expecting \"base64\" or \"hex\".")
+}
@@ -0,0 +1,547 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tidwall/gjson"
+)
+
+// Channel value targeting every focus channel
+const CRON_ALL_CHANNELS = "*"
+
+// Structure of a command run on a schedule
+type CronTask struct {
+	id       string
+	schedule string
+	command  string
+	channel  string
+	author   string
+	catchUp  bool
+	lastRun  time.Time
+	spec     *CronSchedule
+	env      *Environment
+}
+
+// Structure of a parsed cron schedule
+// Each field holds the values the schedule matches
+type CronSchedule struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+var (
+	// Guards the scheduled tasks of every environment, since the scheduler runs outside of the message handler
+	cronMutex sync.Mutex
+
+	// Aliases for common schedules
+	cronAliases = map[string]string{
+		"@hourly":  "0 * * * *",
+		"@daily":   "0 0 * * *",
+		"@weekly":  "0 0 * * 0",
+		"@monthly": "0 0 1 * *",
+		"@yearly":  "0 0 1 1 *",
+	}
+)
+
+// Parse a cron schedule of the form "minute hour day month weekday"
+// @param schedule: string - The schedule to parse
+// @return *CronSchedule - The parsed schedule
+// @return error - The error if the schedule is invalid
+func parseCronSchedule(schedule string) (*CronSchedule, error) {
+
+	if alias, ok := cronAliases[schedule]; ok {
+		schedule = alias
+	}
+
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, errors.New("Error: Invalid schedule \"" + schedule + "\". Expecting \"minute hour day month weekday\".")
+	}
+
+	spec := &CronSchedule{}
+	spec.anyDay = fields[2] == "*"
+	spec.anyWeek = fields[4] == "*"
+
+	err := _parseCronField(fields[0], spec.minutes[:], 0, 59)
+	if err == nil {
+		err = _parseCronField(fields[1], spec.hours[:], 0, 23)
+	}
+	if err == nil {
+		err = _parseCronField(fields[2], spec.days[:], 1, 31)
+	}
+	if err == nil {
+		err = _parseCronField(fields[3], spec.months[:], 1, 12)
+	}
+	if err == nil {
+		err = _parseCronField(fields[4], spec.weekdays[:], 0, 6)
+	}
+	if err != nil {
+		return nil, errors.New("Error: Invalid schedule \"" + schedule + "\". " + err.Error())
+	}
+
+	return spec, nil
+}
+
+// Parse a single field of a cron schedule
+// Supports "*", single values, ranges "a-b", steps "*/n" or "a-b/n" and lists "a,b,c"
+// @param field: string - The field to parse
+// @param values: []bool - The values matched by the field
+// @param min: int - The smallest allowed value
+// @param max: int - The largest allowed value
+// @return error - The error if the field is invalid
+func _parseCronField(field string, values []bool, min int, max int) error {
+
+	for _, part := range strings.Split(field, ",") {
+
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step < 1 {
+				return errors.New("Invalid step \"" + part + "\".")
+			}
+			part = part[:index]
+		}
+
+		start, end := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return errors.New("Invalid value \"" + part + "\".")
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return errors.New("Invalid value \"" + part + "\".")
+				}
+			} else if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return errors.New("Value \"" + part + "\" out of range.")
+		}
+
+		for i := start; i <= end; i += step {
+			values[i] = true
+		}
+	}
+
+	return nil
+}
+
+// Determine if a schedule matches a given minute
+// @param t: time.Time - The time to check
+// @return bool - True if the schedule runs at that time, false otherwise
+func (spec *CronSchedule) matches(t time.Time) bool {
+
+	if !spec.minutes[t.Minute()] || !spec.hours[t.Hour()] || !spec.months[t.Month()] {
+		return false
+	}
+
+	// Like cron, a restricted day and weekday match if either of them does
+	day := spec.days[t.Day()]
+	weekday := spec.weekdays[t.Weekday()]
+	if spec.anyDay || spec.anyWeek {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Get the next time a schedule runs after a given time
+// @param after: time.Time - The time to search from
+// @param until: time.Time - The time to stop searching at
+// @return time.Time - The next run time
+// @return bool - False if the schedule does not run before until
+func (spec *CronSchedule) next(after time.Time, until time.Time) (time.Time, bool) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	for !t.After(until) {
+		if spec.matches(t) {
+			return t, true
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}, false
+}
+
+// Load the scheduled tasks of an environment
+// @param element: gjson.Result - The "cron" element of the environment
+// @param env: *Environment - The environment owning the tasks
+func loadCronTasks(element gjson.Result, env *Environment) {
+
+	cronMutex.Lock()
+	env.cronTasks = make(map[string](*CronTask))
+	cronMutex.Unlock()
+	if !element.Exists() {
+		return
+	}
+
+	for id, value := range element.Map() {
+		err := addCronTask(id, value.Get("schedule").String(), value.Get("command").String(), value.Get("channel").String(), value.Get("author").String(), value.Get("catchUp").Bool(), env)
+		if err != nil {
+			fmt.Printf("Exception encountered while loading task \"%s\". %s Skipping it.\n", id, err.Error())
+		}
+	}
+
+	loadCronState(env)
+}
+
+// Create a new scheduled task
+// @param id: string - The name of the task
+// @param schedule: string - When the task runs
+// @param command: string - The KuriOS command to run
+// @param channel: string - The channel to run the command in, or "*" for every focus channel
+// @param author: string - The user ID of the member the command runs as, or empty in CLI mode
+// @param catchUp: bool - Whether a run missed while offline is made up at startup
+// @param env: *Environment - The environment owning the task
+// @return error - The error if any
+func addCronTask(id string, schedule string, command string, channel string, author string, catchUp bool, env *Environment) error {
+
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if _, ok := env.cronTasks[id]; ok {
+		return errors.New("Error: The task \"" + id + "\" already exists.")
+	}
+
+	if strings.TrimSpace(command) == "" {
+		return errors.New("Error: The task \"" + id + "\" has no command.")
+	}
+
+	spec, err := parseCronSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	if channel == "" {
+		channel = CRON_ALL_CHANNELS
+	}
+
+	env.cronTasks[id] = &CronTask{id, schedule, command, channel, author, catchUp, time.Time{}, spec, env}
+	return nil
+}
+
+// Delete a scheduled task
+// @param id: string - The name of the task
+// @param env: *Environment - The environment owning the task
+// @return error - The error if the task does not exist
+func removeCronTask(id string, env *Environment) error {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if _, ok := env.cronTasks[id]; !ok {
+		return errors.New("Error: The task \"" + id + "\" does not exist.")
+	}
+	delete(env.cronTasks, id)
+	return nil
+}
+
+// Draw the list of scheduled tasks
+// @param env: *Environment - The environment owning the tasks
+// @return string - The list of tasks
+func drawCronTasks(env *Environment) string {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if len(env.cronTasks) == 0 {
+		return "No scheduled tasks."
+	}
+
+	ids := make([]string, 0, len(env.cronTasks))
+	for id := range env.cronTasks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	output := ""
+	for _, id := range ids {
+		task := env.cronTasks[id]
+		lastRun := "never"
+		if !task.lastRun.IsZero() {
+			lastRun = task.lastRun.Format("2006-01-02 15:04")
+		}
+		output += fmt.Sprintf("%s: \"%s\" %s (channel %s, last run %s)\n", id, task.schedule, task.command, task.channel, lastRun)
+	}
+
+	return output
+}
+
+// Save the scheduled tasks as part of an environment
+// @param env: *Environment - The environment owning the tasks
+// @return string - The "cron" element of the environment
+func _saveCronTasks(env *Environment) string {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	tasks := []string{}
+	for _, task := range env.cronTasks {
+		output := jsonString(task.id) + ": {"
+		output += "\"schedule\": " + jsonString(task.schedule) + ","
+		output += "\"command\": " + jsonString(task.command) + ","
+		output += "\"channel\": " + jsonString(task.channel) + ","
+		if task.author != "" {
+			output += "\"author\": " + jsonString(task.author) + ","
+		}
+		output += "\"catchUp\": " + strconv.FormatBool(task.catchUp)
+		output += "}"
+		tasks = append(tasks, output)
+	}
+
+	return "\"cron\": {" + strings.Join(tasks, ",") + "}"
+}
+
+// Load the last run time of the tasks of an environment from the state file
+// @param env: *Environment - The environment owning the tasks
+func loadCronState(env *Environment) {
+
+	data, err := ioutil.ReadFile(*cronStatePath)
+	if err != nil {
+		return
+	}
+
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	state := gjson.ParseBytes(data).Map()
+	for _, task := range env.cronTasks {
+		if value, ok := state[_cronStateKey(task)]; ok {
+			task.lastRun = time.Unix(value.Int(), 0)
+		}
+	}
+}
+
+// Write the last run time of every task to the state file
+// The state is kept apart from the environment so it survives restarts without a save
+func saveCronState() {
+
+	tasks := scheduledTasks()
+	cronMutex.Lock()
+	state := map[string]int64{}
+	for _, task := range tasks {
+		if !task.lastRun.IsZero() {
+			state[_cronStateKey(task)] = task.lastRun.Unix()
+		}
+	}
+	cronMutex.Unlock()
+
+	data, _ := json.Marshal(state)
+	err := os.WriteFile(*cronStatePath, data, 0644)
+	if err != nil {
+		fmt.Println("Error: Could not save the scheduler state,", err)
+	}
+}
+
+// Get the name of a task in the state file
+// Tasks of the guild environments are prefixed with their guild
+// @param task: *CronTask - The task
+// @return string - The name of the task in the state file
+func _cronStateKey(task *CronTask) string {
+	if task.env == nil || task.env.guildID == "" {
+		return task.id
+	}
+	return task.env.guildID + "/" + task.id
+}
+
+// Claim a run of a scheduled task, so that it only happens once
+// @param task: *CronTask - The task
+// @param at: time.Time - The scheduled time of the run
+// @return bool - True if the run is claimed, false if the task already ran at or after that time
+func _claimCronRun(task *CronTask, at time.Time) bool {
+
+	cronMutex.Lock()
+	if !task.lastRun.Before(at) {
+		cronMutex.Unlock()
+		return false
+	}
+	task.lastRun = at
+	cronMutex.Unlock()
+
+	saveCronState()
+	return true
+}
+
+// Run a scheduled task
+// Tasks run on their own goroutine, so a long command does not hold up the others
+// @param session: *discordgo.Session - The discord session to use, or nil in CLI mode
+// @param task: *CronTask - The task to run, its run already claimed
+func runCronTask(session *discordgo.Session, task *CronTask) {
+
+	commands, err := parseCommandLine(task.command)
+	if err != nil {
+		fmt.Printf("Error: Could not parse the command of task \"%s\", %s\n", task.id, err)
+		return
+	}
+
+	// Run the command in the command line interface
+	if session == nil {
+		fmt.Printf("\n[cron %s] %s\n", task.id, task.command)
+		onCliMessage(task.command)
+		return
+	}
+
+	// Run the command in every target channel as its author, with the permissions they have now
+	// Tasks written in the environment file without an author run as the bot itself
+	channels := []string{task.channel}
+	if task.channel == CRON_ALL_CHANNELS {
+		channels = append([]string{}, task.env.focusChannels...)
+	}
+
+	for _, channelID := range channels {
+
+		guildID := task.env.guildID
+		if channel, err := session.State.Channel(channelID); err == nil && channel.GuildID != "" {
+			guildID = channel.GuildID
+		}
+		if task.env.guildID != "" && guildID != task.env.guildID {
+			fmt.Printf("Skipping task \"%s\" in channel %s, which is outside of its server.\n", task.id, channelID)
+			continue
+		}
+
+		member := &discordgo.Member{User: session.State.User}
+		if task.author != "" {
+			member, err = _cronMember(session, guildID, task.author)
+			if err != nil {
+				fmt.Printf("Skipping task \"%s\" in channel %s, its author is not a member of the server.\n", task.id, channelID)
+				continue
+			}
+			if !HasPermission(session, member, guildID, "cron") {
+				fmt.Printf("Skipping task \"%s\" in channel %s, its author is no longer allowed to schedule tasks.\n", task.id, channelID)
+				continue
+			}
+		}
+
+		bindChannel(channelID, guildID)
+		initCurrentDirectory(channelID, &task.env.root)
+
+		message := &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: channelID,
+			GuildID:   guildID,
+			Content:   task.command,
+			Author:    member.User,
+			Member:    member,
+		}}
+
+		executeDiscordCommand(session, message, commands)
+	}
+}
+
+// Get the member a task runs as
+// @param session: *discordgo.Session - The discord session to use
+// @param guildID: string - The guild of the member
+// @param userID: string - The user ID of the member
+// @return *discordgo.Member - The member
+// @return error - The error if the user is not a member of the guild
+func _cronMember(session *discordgo.Session, guildID string, userID string) (*discordgo.Member, error) {
+
+	if guildID == "" {
+		return nil, errors.New("Error: The task is not part of a server.")
+	}
+
+	member, err := session.State.Member(guildID, userID)
+	if err != nil {
+		member, err = session.GuildMember(guildID, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if member.User == nil {
+		member.User = &discordgo.User{ID: userID}
+	}
+
+	return member, nil
+}
+
+// Run the scheduled tasks every minute
+// @param session: *discordgo.Session - The discord session to use, or nil in CLI mode
+func startScheduler(session *discordgo.Session) {
+
+	// Make up for the runs missed while offline, once, as of the latest of them
+	// The latest missed run is kept either way so it is not reported again at the next startup
+	now := time.Now()
+	for _, task := range scheduledTasks() {
+		cronMutex.Lock()
+		lastRun := task.lastRun
+		cronMutex.Unlock()
+		if lastRun.IsZero() {
+			continue
+		}
+
+		missed, ok := task.spec.next(lastRun, now)
+		if !ok {
+			continue
+		}
+		for later, ok := task.spec.next(missed, now); ok; later, ok = task.spec.next(missed, now) {
+			missed = later
+		}
+
+		if !_claimCronRun(task, missed) {
+			continue
+		}
+		if task.catchUp {
+			fmt.Printf("Running task \"%s\" missed at %s.\n", task.id, missed.Format("2006-01-02 15:04"))
+			go runCronTask(session, task)
+		} else {
+			fmt.Printf("Skipping task \"%s\" missed at %s.\n", task.id, missed.Format("2006-01-02 15:04"))
+		}
+	}
+
+	go func() {
+		last := time.Now().Truncate(time.Minute)
+		for {
+			// Wake up at the start of every minute
+			now := time.Now()
+			time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+
+			// Go through every minute since the last wake up, in case some were slept through
+			tick := time.Now().Truncate(time.Minute)
+			for at := last.Add(time.Minute); !at.After(tick); at = at.Add(time.Minute) {
+				for _, task := range scheduledTasks() {
+					if task.spec.matches(at) && _claimCronRun(task, at) {
+						go runCronTask(session, task)
+					}
+				}
+			}
+			if tick.After(last) {
+				last = tick
+			}
+		}
+	}()
+}
+
+// Get a snapshot of the scheduled tasks of every environment
+// Tasks may add or remove other tasks while they run
+// @return []*CronTask - The scheduled tasks
+func scheduledTasks() []*CronTask {
+	envs := allEnvironments()
+
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	tasks := []*CronTask{}
+	for _, env := range envs {
+		for _, task := range env.cronTasks {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
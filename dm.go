@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Commands that only make sense inside a guild channel
+var guildOnlyCommands = map[string]bool{
+	"new":      true,
+	"close":    true,
+	"invite":   true,
+	"kick":     true,
+	"sessions": true,
+	"guild":    true,
+}
+
+// **DISCORD FEATURE ONLY**
+// Set up a direct message so it is played in the home guild
+// The direct message terminal is enabled by "dm_terminal" and "home_guild" in the configuration file
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The direct message
+// @return bool - True if the message can be run as a command, false otherwise
+func openDirectMessage(session *discordgo.Session, message *discordgo.MessageCreate) bool {
+
+	enabled, _ := config["dm_terminal"].(bool)
+	homeGuild, _ := config["home_guild"].(string)
+	if !enabled || homeGuild == "" {
+		return false
+	}
+
+	// Roles come from the home guild, so only its members can play
+	member, err := session.State.Member(homeGuild, message.Author.ID)
+	if err != nil {
+		member, err = session.GuildMember(homeGuild, message.Author.ID)
+		if err != nil {
+			return false
+		}
+	}
+	if member.User == nil {
+		member.User = message.Author
+	}
+
+	message.GuildID = homeGuild
+	message.Member = member
+
+	// Each player keeps their own current directory
+	bindChannel(message.ChannelID, homeGuild)
+	initCurrentDirectory(message.ChannelID, &guildEnvironment(homeGuild).root)
+
+	return true
+}
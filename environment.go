@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/tidwall/gjson"
@@ -12,8 +13,9 @@ import (
 
 // Load a specified environment into memory
 // @param path: string - The environment path to load
+// @param env: *Environment - The environment to load into
 // @return error - The error if any
-func loadEnvironment(path string) error {
+func loadEnvironment(path string, env *Environment) error {
 
 	// Load directory tree data from the specified path
 	directoryJson, err := os.Open(path)
@@ -30,7 +32,7 @@ func loadEnvironment(path string) error {
 	dirStruc := gjson.Parse(string(byteValue))
 
 	// Clear variables
-	systemVariables = map[string](*SystemVariable){
+	env.variables = map[string](*SystemVariable){
 		"version": &(SystemVariable{"version", true, VERSION}), // Current version of the program
 		"time":    &(SystemVariable{"time", false, "0"}),       // Current time
 	}
@@ -39,7 +41,7 @@ func loadEnvironment(path string) error {
 	if variables.Exists() {
 		// Load each variable to the environment
 		for name, value := range variables.Map() {
-			err := createSystemVariable(name, value.String())
+			err := createSystemVariable(name, value.String(), env)
 
 			// Check to see if the variable was created properly
 			if err != nil {
@@ -49,24 +51,30 @@ func loadEnvironment(path string) error {
 	}
 
 	// Clear permissions
-	systemPermissions = make(map[string]([]string))
-	priorityPermissions = []string{}
+	env.permissions = make(map[string]([]string))
+	env.priority = []string{}
 	// Load permissions if they exist
 	permissions := dirStruc.Get("perms")
 	if permissions.Exists() {
 		// Load each permission to the environment
 		for name, value := range permissions.Map() {
-			priorityPermissions = append([]string{name}, priorityPermissions...)
+			env.priority = append([]string{name}, env.priority...)
 			// Create the permission array
 			array := make([]string, 0)
 			for _, element := range value.Array() {
 				array = append(array, element.String())
 			}
 
-			systemPermissions[name] = array
+			env.permissions[name] = array
 		}
 	}
 
+	// Load the scheduled tasks if they exist
+	loadCronTasks(dirStruc.Get("cron"), env)
+
+	// The order of the root directory is set next to the directory structure
+	rootOrder := _loadOrder(dirStruc)
+
 	// Access the directory structure element from the JSON
 	dirStruc = dirStruc.Get("struct")
 	if !dirStruc.Exists() {
@@ -80,11 +88,11 @@ func loadEnvironment(path string) error {
 	}
 
 	// Create and set the root directory
-	root = Folder{"", "/", folders, files, []string{"*", "*"}, false, ""}
+	env.root = Folder{"", "/", folders, files, []string{"*", "*"}, false, "", map[string]string{}, false, "", false, nil, rootOrder, Metadata{}}
+	env.path = path
 
 	// Reset the current directory
-	currentDir = map[string]*Folder{}
-	currentDir["default"] = &root
+	resetCurrentDirectories(env)
 
 	return nil
 }
@@ -144,6 +152,15 @@ func _loadElement(element *gjson.Result, path string) (map[string]*Folder, map[s
 			}
 		}
 
+		// Load the hooks and visibility of the element
+		hooks := _loadHooks(value)
+		hidden := value.Get("hidden").Bool()
+		revealWhen := ""
+		if value.Get("revealWhen").IsObject() {
+			revealWhen = value.Get("revealWhen").Raw
+		}
+		unlockedOnce := value.Get("unlockedOnce").Bool()
+
 		if elType == "folder" {
 
 			// Load subfolder
@@ -154,12 +171,12 @@ func _loadElement(element *gjson.Result, path string) (map[string]*Folder, map[s
 				return nil, nil, err
 			}
 
-			folders[name] = &Folder{name, path, subFolder, subFiles, availableBetween, locked, lockKey}
+			folders[name] = &Folder{name, path, subFolder, subFiles, availableBetween, locked, lockKey, hooks, hidden, revealWhen, unlockedOnce, nil, _loadOrder(value), loadMetadata(value)}
 
 		} else if elType == "file" {
 
 			// Load file
-			subFile := File{name, path, "", "", availableBetween, locked, lockKey}
+			subFile := File{name, path, "", "", availableBetween, locked, lockKey, hooks, hidden, revealWhen, unlockedOnce, value.Get("hash").String(), nil, "", loadMetadata(value), loadHistory(value)}
 
 			// Check to see if file has cache
 			if value.Get("cache").Exists() {
@@ -171,6 +188,33 @@ func _loadElement(element *gjson.Result, path string) (map[string]*Folder, map[s
 
 			files[name] = &subFile
 
+		} else if elType == "link" {
+
+			// Load link, its target is only resolved when accessed
+			link := File{name, path, "", "", availableBetween, locked, lockKey, hooks, hidden, revealWhen, unlockedOnce, "", nil, value.Get("target").String(), loadMetadata(value), nil}
+			if link.link == "" {
+				fmt.Printf("Exception encountered while loading link \"%s\". Missing target.\n", path+name)
+				continue
+			}
+			files[name] = &link
+
+		} else if elType == "mount" {
+
+			// Mounts are read-only unless asked otherwise
+			readOnly := true
+			if value.Get("readonly").Exists() {
+				readOnly = value.Get("readonly").Bool()
+			}
+
+			// Load mount, its children are only loaded when accessed
+			mount, err := newMount(value.Get("source").String(), readOnly)
+			if err != nil {
+				fmt.Printf("Exception encountered while loading mount \"%s\". %s\n", path+name, err.Error())
+				continue
+			}
+
+			folders[name] = &Folder{name, path, map[string]*Folder{}, map[string]*File{}, availableBetween, locked, lockKey, hooks, hidden, revealWhen, unlockedOnce, &MountNode{mount, "", false}, _loadOrder(value), loadMetadata(value)}
+
 		} else {
 			return nil, nil, errors.New("Unknown element type for " + path + name)
 		}
@@ -180,15 +224,41 @@ func _loadElement(element *gjson.Result, path string) (map[string]*Folder, map[s
 	return folders, files, nil
 }
 
-// Save the current environment to a specified path
+// Load the order of the children of a folder
+// @param element: gjson.Result - The folder
+// @return []string - The names listed first, in order
+func _loadOrder(element gjson.Result) []string {
+	order := []string{}
+	for _, name := range element.Get("order").Array() {
+		order = append(order, name.String())
+	}
+	return order
+}
+
+// Save the order of the children of a folder
+// @param order: []string - The names listed first, in order
+// @return string - The order, followed by a comma, or nothing if there is no order
+func _saveOrder(order []string) string {
+	if len(order) == 0 {
+		return ""
+	}
+	names := []string{}
+	for _, name := range order {
+		names = append(names, jsonString(name))
+	}
+	return "\"order\": [" + strings.Join(names, ", ") + "],"
+}
+
+// Save an environment to a specified path
 // @param path: string - The path to save the environment to
+// @param env: *Environment - The environment to save
 // @return error - The error if any
-func saveEnvironment(path string) error {
+func saveEnvironment(path string, env *Environment) error {
 
 	// Handle variables to save
-	outputEnvVar := "\"vars\": {"    // Start the variables
-	varCount := len(systemVariables) // Count the number of variables remaining
-	for _, variable := range systemVariables {
+	outputEnvVar := "\"vars\": {"  // Start the variables
+	varCount := len(env.variables) // Count the number of variables remaining
+	for _, variable := range env.variables {
 
 		// Ignore immutable variables
 		if !(*variable).Immutable {
@@ -207,9 +277,9 @@ func saveEnvironment(path string) error {
 	outputEnvVar += "}," // Close the variables
 
 	// Handle permissions to save
-	outputEnvPerm := "\"perms\": {"     // Start the permissions
-	permCount := len(systemPermissions) // Count the number of permissions remaining
-	for name, permissions := range systemPermissions {
+	outputEnvPerm := "\"perms\": {"   // Start the permissions
+	permCount := len(env.permissions) // Count the number of permissions remaining
+	for name, permissions := range env.permissions {
 		outputEnvPerm += "\"" + name + "\": ["
 
 		for i, perm := range permissions {
@@ -230,13 +300,16 @@ func saveEnvironment(path string) error {
 		permCount--
 	}
 
+	// Record the hash of the cache files, so altered files are noticed
+	hashCacheFiles(&env.root)
+
 	// Handle the directory structure to save
-	outputEnvStruct := "\"struct\": {" // Start the directory structure
-	folderCount := len(root.folders)   // Count the number of folders remaining
-	for _, folder := range root.folders {
+	outputEnvStruct := "\"struct\": {"   // Start the directory structure
+	folderCount := len(env.root.folders) // Count the number of folders remaining
+	for _, folder := range env.root.folders {
 
-		// Add a comma if not the last element
-		if folderCount > 1 {
+		// Add a comma if not the last element or if there are files
+		if folderCount > 1 || len(env.root.files) > 0 {
 			outputEnvStruct += _saveElement(folder) + ","
 		} else {
 			outputEnvStruct += _saveElement(folder) + ""
@@ -244,10 +317,25 @@ func saveEnvironment(path string) error {
 
 		folderCount-- // Decrement the folder count
 	}
+	fileCount := len(env.root.files) // Count the number of files remaining
+	for _, file := range env.root.files {
+
+		// Add a comma if not the last element
+		if fileCount > 1 {
+			outputEnvStruct += _saveFile(file) + ","
+		} else {
+			outputEnvStruct += _saveFile(file)
+		}
+
+		fileCount-- // Decrement the file count
+	}
 	outputEnvStruct += "}" // Close the directory structure
 
-	// Concate the variables and directory structure
-	outputEnv := "{" + outputEnvVar + outputEnvStruct + "}"
+	// Handle the scheduled tasks to save
+	outputEnvCron := _saveCronTasks(env) + ","
+
+	// Concate the variables, scheduled tasks, root order and directory structure
+	outputEnv := "{" + outputEnvVar + outputEnvCron + _saveOrder(env.root.order) + outputEnvStruct + "}"
 
 	// Write the environment to the specified path
 	file, err := os.Create(path)
@@ -270,14 +358,38 @@ func saveEnvironment(path string) error {
 // @return string - The string representation of the element
 func _saveElement(current *Folder) string {
 
-	output := "\"" + (*current).name + "\": {" // Start the folder
-	output += "\"type\": \"folder\","          // Set the type to folder
-	if (*current).availableBetween[0] != "*" && (*current).availableBetween[1] != "*" {
+	output := jsonString((*current).name) + ": {" // Start the folder
+
+	// Mounts are saved by their source, their contents stay on the host
+	mounted := (*current).mounted != nil
+	if mounted {
+		output += "\"type\": \"mount\","
+		output += "\"source\": " + jsonString((*current).mounted.mount.source) + ","
+		output += "\"readonly\": " + fmt.Sprint((*current).mounted.mount.readOnly) + ","
+	} else {
+		output += "\"type\": \"folder\"," // Set the type to folder
+	}
+	if (*current).availableBetween[0] != "*" || (*current).availableBetween[1] != "*" {
 		output += "\"availableBetween\": [\"" + (*current).availableBetween[0] + "\", \"" + (*current).availableBetween[1] + "\"],"
 	}
 	if (*current).locked {
 		output += "\"locked\": true,"
-		output += "\"key\": \"" + (*current).key + "\","
+		output += "\"key\": " + jsonString((*current).key) + ","
+	}
+	if (*current).hidden {
+		output += "\"hidden\": true,"
+	}
+	if (*current).revealWhen != "" {
+		output += "\"revealWhen\": " + (*current).revealWhen + ","
+	}
+	if (*current).unlockedOnce {
+		output += "\"unlockedOnce\": true,"
+	}
+	output += _saveHooks((*current).hooks)
+	output += _saveOrder((*current).order)
+	output += _saveMetadata((*current).meta)
+	if mounted {
+		return strings.TrimSuffix(output, ",") + "}"
 	}
 	output += "\"children\": {" // Start the children
 
@@ -297,13 +409,13 @@ func _saveElement(current *Folder) string {
 	}
 
 	for _, file := range (*current).files {
-		output += "\"" + file.name + "\": {" // Start the file
-		output += "\"type\": \"file\","      // Set the type to file
-		if file.cache != "" {
-			output += "\"cache\": \"" + file.cache + "\"," // Set the cache to the file
+
+		// Add a comma if not the last element
+		if fileCount > 1 {
+			output += _saveFile(file) + ","
+		} else {
+			output += _saveFile(file)
 		}
-		output += "\"data\": \"" + string(file.data) + "\"" // Set the data to the file
-		output += "}"                                       // End the file
 
 		fileCount-- // Decrement the file remaining count
 	}
@@ -313,3 +425,52 @@ func _saveElement(current *Folder) string {
 	return output
 
 }
+
+// Save a file to its string representation
+// @param file: *File - The file to save
+// @return string - The string representation of the file
+func _saveFile(file *File) string {
+	output := jsonString(file.name) + ": {" // Start the file
+
+	// Links are saved by their target, with the same state as files
+	if file.link != "" {
+		output += "\"type\": \"link\","
+	} else {
+		output += "\"type\": \"file\"," // Set the type to file
+		if file.cache != "" {
+			output += "\"cache\": " + jsonString(file.cache) + "," // Set the cache to the file
+		}
+		if file.hash != "" {
+			output += "\"hash\": \"" + file.hash + "\","
+		}
+	}
+	if file.availableBetween[0] != "*" || file.availableBetween[1] != "*" {
+		output += "\"availableBetween\": [\"" + file.availableBetween[0] + "\", \"" + file.availableBetween[1] + "\"],"
+	}
+	if file.locked {
+		output += "\"locked\": true,"
+		output += "\"key\": " + jsonString(file.key) + ","
+	}
+	if file.hidden {
+		output += "\"hidden\": true,"
+	}
+	if file.revealWhen != "" {
+		output += "\"revealWhen\": " + file.revealWhen + ","
+	}
+	if file.unlockedOnce {
+		output += "\"unlockedOnce\": true,"
+	}
+	output += _saveHooks(file.hooks)
+	output += _saveMetadata(file.meta)
+	if file.link != "" {
+		output += "\"target\": " + jsonString(file.link)
+		output += "}"
+		return output
+	}
+
+	output += _saveHistory(file.history)
+	output += "\"data\": " + jsonString(file.data) // Set the data to the file
+	output += "}"                                  // End the file
+
+	return output
+}
@@ -14,63 +14,146 @@ type File struct {
 	availableBetween []string
 	locked           bool
 	key              string
+	hooks            map[string]string
+	hidden           bool
+	revealWhen       string
+	unlockedOnce     bool
+	hash             string
+	mounted          *MountNode
+	link             string
+	meta             Metadata
+	history          []Revision
 }
 
+// Read the contents of a file from its data or its cache
+// @param file: *File - The file to read
+// @return []byte - The contents of the file
+// @return error - The error if the cache could not be read
+func readFile(file *File) ([]byte, error) {
+
+	if (*file).mounted != nil {
+		return (*file).mounted.mount.read((*file).mounted.entry)
+	}
+
+	if (*file).cache == "" {
+		return []byte((*file).data), nil
+	}
+
+	return loadBlob((*file).cache, (*file).hash)
+}
+
+// Retrive a file
+// @param path: string - The path to the file
+// @param formatting: bool - Whether relative paths start from the current directory rather than the root
+// @return *File - The file
+// @return error - The *PathError if the file cannot be accessed
 func getFile(path string, formatting bool, channel string) (*File, error) {
 
-	// Support relative path formatting
-	if formatting {
-		err := formatPath(&path, channel)
+	_, file, err := resolvePath(path, channel, formatting, true)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, err
+	if file == nil {
+		from := ""
+		if formatting {
+			from = channel
 		}
+		return nil, &PathError{errPathNotFile, normalizePath(path, from)}
 	}
 
-	currDir := &root
+	return file, nil
+}
 
-	// Get travel steps
-	pathRoute := strings.Split(path, "/")
+// Retrive a file to read it
+// Typing the path of the file reveals it, and its "onRead" hooks are run
+// @param path: string - The path to the file, absolute or relative
+// @param channel: string - The channel used to resolve relative paths
+// @param context: *HookContext - Who is reading the file and where
+// @return *File - The file
+// @return error - The *PathError if the file cannot be accessed
+func openFile(path string, channel string, context *HookContext) (*File, error) {
 
-	// Navigate through the path
-	for i, p := range pathRoute {
+	file, err := getFile(path, true, channel)
+	if err != nil {
+		return nil, err
+	}
 
-		// Ignore empty path
-		if strings.TrimSpace(p) == "" {
-			continue
-		}
+	revealIfTyped(&file.hidden, file.revealWhen)
+	fireHook(file.hooks, "onRead", context)
 
-		if i == len(pathRoute)-1 {
-			if _, okFile := (*currDir).files[p]; okFile {
-				f := (*currDir).files[p]
-				if !IsTimeAvailable((*f).availableBetween[0], (*f).availableBetween[1]) {
-					return nil, errors.New("Error: The file is unavailable at the moment.")
-				}
-				if (*f).locked {
-					return nil, errors.New("Error: The file is locked.")
-				}
-				return f, nil
-			} else {
-				return nil, errors.New("Error: Could not find the file " + path + ".")
-			}
-		}
+	return file, nil
+}
 
-		// Travel to the next directory
-		if _, ok := (*currDir).folders[p]; ok {
-			f := (*currDir).folders[p]
-			if !IsTimeAvailable((*f).availableBetween[0], (*f).availableBetween[1]) {
-				return nil, errors.New("Error: The directory \"" + (*f).path + (*f).name + "\" is unavailable at the moment.")
-			}
-			if (*f).locked {
-				return nil, errors.New("Error: The directory \"" + (*f).path + (*f).name + "\" is locked.")
-			}
-			currDir = f
-
-		} else {
-			return nil, errors.New("Error: Could not find the file " + path + ".")
+// Write the contents of a file, creating the file if it does not exist
+// @param path: string - The path to the file
+// @param data: string - The new contents of the file
+// @param channel: string - The channel used to resolve relative paths
+// @param author: string - The user ID of the author of the change, or empty if unknown
+// @param message: string - What the change was, kept in the history of the file
+// @return *File - The file written
+// @return error - The error if any
+func writeFile(path string, data string, channel string, author string, message string) (*File, error) {
+
+	// Overwrite the file if it already exists
+	file, err := getFile(path, true, channel)
+	if err == nil && (*file).mounted != nil {
+		err = (*file).mounted.mount.write((*file).mounted.entry, data)
+		if err != nil {
+			return nil, err
 		}
+		(*file).meta.touch(author)
+		return file, nil
+	}
+	if err == nil {
+		keepRevision(file)
+		(*file).data = data
+		(*file).cache = ""
+		(*file).hash = ""
+		(*file).meta.touch(author)
+		addRevision(file, author, message)
+		return file, nil
+	}
+
+	// Find the directory holding the new file
+	dirPath, name := splitPath(normalizePath(path, channel))
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("Error: Invalid file name \"" + path + "\".")
+	}
+
+	parent, err := getDirectory(dirPath, false, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	expandFolder(parent)
+	if _, ok := (*parent).folders[name]; ok {
+		return nil, errors.New("Error: \"" + name + "\" is a directory.")
+	}
+	if f, ok := (*parent).files[name]; ok && f.link != "" {
+		return nil, errors.New("Error: The link \"" + name + "\" does not lead to a file.")
+	}
+	if f, ok := (*parent).files[name]; ok && (f.locked || !IsTimeAvailable(f.availableBetween[0], f.availableBetween[1])) {
+		return nil, errors.New("Error: Cannot write to the file \"" + name + "\".")
+	}
+
+	file = &File{name, (*parent).path + (*parent).name + "/", data, "", []string{"*", "*"}, false, "", map[string]string{}, false, "", false, "", nil, "", newMetadata(author), nil}
+	if parent == &channelEnvironment(channel).root {
+		file.path = "/"
+	}
 
+	// Files created in a mounted directory are written to the host
+	if (*parent).mounted != nil {
+		file.data = ""
+		file.mounted = &MountNode{(*parent).mounted.mount, strings.TrimPrefix((*parent).mounted.entry+"/"+name, "/"), false}
+		err = file.mounted.mount.write(file.mounted.entry, data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		addRevision(file, author, message)
 	}
+	(*parent).files[name] = file
 
-	return nil, errors.New("Error: Could not find the file " + path + ".")
+	return file, nil
 }
@@ -1,10 +1,5 @@
 package main
 
-import (
-	"errors"
-	"strings"
-)
-
 // Structure containing other directories and files
 type Folder struct {
 	name             string
@@ -14,197 +9,184 @@ type Folder struct {
 	availableBetween []string
 	locked           bool
 	key              string
+	hooks            map[string]string
+	hidden           bool
+	revealWhen       string
+	unlockedOnce     bool
+	mounted          *MountNode
+	order            []string
+	meta             Metadata
 }
 
 // Get the current working directory
 // @return string - The current working directory
 func getCurrentDirectoryPath(channel string) string {
-	return (*(currentDir)[channel]).path + (*(currentDir)[channel]).name
+	directory := getCurrentDirectory(channel)
+	return directory.path + directory.name
+}
+
+// Get the current directory of a channel
+// @param channel: string - The channel
+// @return *Folder - The current directory, or nil if the channel has none
+func getCurrentDirectory(channel string) *Folder {
+	currentDirMutex.Lock()
+	defer currentDirMutex.Unlock()
+	return currentDir[channel]
+}
+
+// Set the current directory of a channel
+// @param channel: string - The channel
+// @param directory: *Folder - The new current directory
+func setCurrentDirectory(channel string, directory *Folder) {
+	currentDirMutex.Lock()
+	defer currentDirMutex.Unlock()
+	currentDir[channel] = directory
+}
+
+// Give a channel a current directory if it has none yet
+// @param channel: string - The channel
+// @param directory: *Folder - The current directory to start from
+func initCurrentDirectory(channel string, directory *Folder) {
+	currentDirMutex.Lock()
+	defer currentDirMutex.Unlock()
+	if _, ok := currentDir[channel]; !ok {
+		currentDir[channel] = directory
+	}
+}
+
+// Forget the current directory of a channel
+// @param channel: string - The channel
+func clearCurrentDirectory(channel string) {
+	currentDirMutex.Lock()
+	defer currentDirMutex.Unlock()
+	delete(currentDir, channel)
 }
 
 // Retrive a directory folder
 // @param path: string - The path to the directory
-// @param formatting: bool - Whether or not formatting syntax is used
+// @param formatting: bool - Whether relative paths start from the current directory rather than the root
 // @return *Folder - The directory folder
+// @return error - The *PathError if the directory cannot be accessed
 func getDirectory(path string, formatting bool, channel string) (*Folder, error) {
 
-	// Check to see if the specified path is the root
-	if path == "/" {
-		return &root, nil
+	folder, _, err := resolvePath(path, channel, formatting, true)
+	if err != nil {
+		return nil, err
 	}
 
-	// Support relative path formatting
-	if formatting {
-		err := formatPath(&path, channel)
-
-		if err != nil {
-			return nil, err
+	if folder == nil {
+		from := ""
+		if formatting {
+			from = channel
 		}
+		return nil, &PathError{errPathNotDirectory, normalizePath(path, from)}
 	}
 
-	currDir := &root
-
-	// Get travel steps
-	pathRoute := strings.Split(path, "/")
-
-	// Navigate through the path
-	for _, p := range pathRoute {
-
-		// Ignore empty path
-		if strings.TrimSpace(p) == "" {
-			continue
-		}
-
-		// Travel to the next directory
-		if _, ok := (*currDir).folders[p]; ok {
-			f := (*currDir).folders[p]
-			if !IsTimeAvailable((*f).availableBetween[0], (*f).availableBetween[1]) {
-				return nil, errors.New("Error: The directory is unavailable at the moment.")
-			}
-			if (*f).locked {
-				return f, errors.New("Error: The directory \"" + (*f).path + (*f).name + "\" is locked.")
-			}
-			currDir = f
-
-		} else {
-			return nil, errors.New("Error: Could not find the directory.")
-		}
+	return folder, nil
+}
 
+// Change the current directory of a channel
+// Typing the path of the directory reveals it, and its "onEnter" hooks are run once inside
+// @param path: string - The path to the directory, absolute or relative
+// @param channel: string - The channel changing directory
+// @param context: *HookContext - Who is changing directory and where
+// @return *Folder - The new current directory
+// @return error - The *PathError if the directory cannot be accessed
+func enterDirectory(path string, channel string, context *HookContext) (*Folder, error) {
+
+	folder, err := getDirectory(path, true, channel)
+	if err != nil {
+		return nil, err
 	}
 
-	return currDir, nil
+	revealIfTyped(&folder.hidden, folder.revealWhen)
+	setCurrentDirectory(channel, folder)
+	fireHook(folder.hooks, "onEnter", context)
+
+	return folder, nil
 }
 
 // Draw the directory tree (only the first level)
 // @param directory: *Folder - The directory to draw
 // @param maxDepth: int - Max tree depth to draw
+// @param viewer: *Viewer - Who is looking at the directory tree
 // @return string - The directory tree
-func drawDirectory(directory *Folder, maxDepth int) string {
-
-	// Initialize the tree name
-	treeOutput := directory.name + "/\n|\n"
-
-	// Generate the tree recursively
-	tree := drawDirectoryUtil(directory, "", maxDepth)
-	for _, line := range tree {
-		treeOutput += line + "\n"
-	}
-
-	return treeOutput
+func drawDirectory(directory *Folder, maxDepth int, viewer *Viewer) string {
+	return PlainRenderer{}.renderTree(buildTree(directory, maxDepth, viewer)).text
 }
 
-// Utility function to draw the directory tree recursively
-// @param directory: *Folder - The directory to draw
-// @param prefix: string - The prefix to use before each line of the tree
+// Build the directory tree recursively
+// @param directory: *Folder - The directory to explore
 // @param depth: int - The current depth of the tree. At depth 0, the discovery of the directory is done.
-// @return []string - The directory tree as a list of lines
-func drawDirectoryUtil(directory *Folder, prefix string, depth int) []string {
+// @param viewer: *Viewer - Who is looking at the directory tree
+// @return *TreeEntry - The directory tree
+func buildTree(directory *Folder, depth int, viewer *Viewer) *TreeEntry {
 
-	// Get the size of the directory
-	numOfFolders := len(directory.folders)
-	countFolder := 0
-	numOfFiles := len(directory.files)
-	countFile := 0
+	entry := &TreeEntry{directory.name, getFolderPath(directory), true, nodeState(directory.availableBetween, directory.locked), []*TreeEntry{}, false, ""}
 
-	dirTree := make([]string, 0)
+	// Get the visible content of the directory
+	folders := visibleFolders(directory, viewer)
+	files := visibleFiles(directory, viewer)
 
 	// Check if the max discovery depth has been reached
+	// If there are items remaining, we show that there are more items
 	if depth < 0 {
+		entry.truncated = len(folders)+len(files) > 0
+		return entry
+	}
 
-		// Check if there are any items remaining
-		// If there are no items remaining, then we are at the end of the tree
-		// Otherwise, we show that there are more items
-		if (numOfFolders + numOfFiles) > 0 {
-			dirTree = append(dirTree, prefix+"└─(...)")
+	// Add the folders, and their contents if they can be accessed
+	for _, folder := range folders {
+		if nodeState(folder.availableBetween, folder.locked) == NODE_OK {
+			entry.children = append(entry.children, buildTree(folder, depth-1, viewer))
+		} else {
+			entry.children = append(entry.children, &TreeEntry{folder.name, getFolderPath(folder), true, nodeState(folder.availableBetween, folder.locked), []*TreeEntry{}, false, ""})
 		}
-
-		return dirTree
 	}
 
-	// Draw the folders
-	for _, folder := range directory.folders {
-
-		timeAvailable := IsTimeAvailable((*folder).availableBetween[0], (*folder).availableBetween[1])
-
-		// Draw sub folder names
-		if countFolder == numOfFolders-1 && numOfFiles == 0 {
-			// Reached the last folder
-
-			if timeAvailable && !(*folder).locked {
-				// Not locked and time available
-				dirTree = append(dirTree, prefix+"└─"+folder.name+"/")
-			} else if !timeAvailable && !(*folder).locked {
-				// Not locked and time not available
-				dirTree = append(dirTree, prefix+"└─"+folder.name+"/ (unavailable)")
-			} else {
-				// Locked and time available or not available
-				dirTree = append(dirTree, prefix+"└─"+folder.name+"/ (locked)")
-			}
-
-			// Draw sub folder contents
-			if timeAvailable && !(*folder).locked {
-				content := drawDirectoryUtil(folder, prefix+"  ", depth-1)
-				dirTree = append(dirTree, content...)
-			}
+	// Add the files
+	for _, file := range files {
+		entry.children = append(entry.children, &TreeEntry{file.name, file.path + file.name, false, nodeState(file.availableBetween, file.locked), nil, false, file.link})
+	}
 
-		} else {
+	return entry
+}
 
-			if timeAvailable && !(*folder).locked {
-				// Not locked and time available
-				dirTree = append(dirTree, prefix+"├─"+folder.name+"/")
-			} else if !timeAvailable && !(*folder).locked {
-				// Not locked and time not available
-				dirTree = append(dirTree, prefix+"├─"+folder.name+"/ (unavailable)")
-			} else {
-				// Locked and time available or not available
-				dirTree = append(dirTree, prefix+"├─"+folder.name+"/ (locked)")
-			}
-
-			// Draw sub folder contents
-			if timeAvailable && !(*folder).locked {
-				content := drawDirectoryUtil(folder, prefix+"| ", depth-1)
-				dirTree = append(dirTree, content...)
-			}
+// Get the full path of a folder
+// @param folder: *Folder - The folder
+// @return string - The path of the folder
+func getFolderPath(folder *Folder) string {
+	return folder.path + folder.name
+}
 
+// Get the folders of a directory that a viewer can see
+// @param directory: *Folder - The directory
+// @param viewer: *Viewer - Who is looking at the directory
+// @return []*Folder - The visible folders, sorted
+func visibleFolders(directory *Folder, viewer *Viewer) []*Folder {
+	expandFolder(directory)
+	folders := make([]*Folder, 0, len(directory.folders))
+	for _, folder := range directory.folders {
+		if isVisible(folder.name, folder.hidden, folder.revealWhen, viewer) {
+			folders = append(folders, folder)
 		}
-
-		countFolder++
 	}
+	sortFolders(directory, folders)
+	return folders
+}
 
-	// Draw the files
+// Get the files of a directory that a viewer can see
+// @param directory: *Folder - The directory
+// @param viewer: *Viewer - Who is looking at the directory
+// @return []*File - The visible files, sorted
+func visibleFiles(directory *Folder, viewer *Viewer) []*File {
+	expandFolder(directory)
+	files := make([]*File, 0, len(directory.files))
 	for _, file := range directory.files {
-
-		timeAvailable := IsTimeAvailable((*file).availableBetween[0], (*file).availableBetween[1])
-
-		// Draw file names
-		if countFile == numOfFiles-1 {
-			// Reached the last file
-			if timeAvailable && !(*file).locked {
-				// Not locked and time available
-				dirTree = append(dirTree, prefix+"└─"+file.name)
-			} else if !timeAvailable && !(*file).locked {
-				// Not locked and time not available
-				dirTree = append(dirTree, prefix+"└─"+file.name+" (unavailable)")
-			} else {
-				// Locked and time available or not available
-				dirTree = append(dirTree, prefix+"└─"+file.name+" (locked)")
-			}
-
-		} else {
-			if timeAvailable && !(*file).locked {
-				// Not locked and time available
-				dirTree = append(dirTree, prefix+"├─"+file.name)
-			} else if !timeAvailable && !(*file).locked {
-				// Not locked and time not available
-				dirTree = append(dirTree, prefix+"├─"+file.name+" (unavailable)")
-			} else {
-				// Locked and time available or not available
-				dirTree = append(dirTree, prefix+"├─"+file.name+" (locked)")
-			}
+		if isVisible(file.name, file.hidden, file.revealWhen, viewer) {
+			files = append(files, file)
 		}
-
-		countFile++
 	}
-
-	return dirTree
+	sortFiles(directory, files)
+	return files
 }
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tidwall/gjson"
+)
+
+// Structure of the state of a guild: its directory tree, variables, permissions and limits
+type Environment struct {
+	guildID         string
+	path            string
+	root            Folder
+	variables       map[string](*SystemVariable)
+	permissions     map[string]([]string)
+	priority        []string
+	cronTasks       map[string](*CronTask)
+	focusChannels   []string
+	maxChannels     int
+	sessionsPerUser int
+}
+
+var (
+	// Environment used in CLI mode and by guilds without an environment of their own
+	defaultEnvironment *Environment = newEnvironment("", "")
+
+	// Environments of the provisioned guilds, by guild ID
+	environments map[string](*Environment) = map[string](*Environment){}
+
+	// Guild each terminal channel belongs to
+	channelGuilds map[string]string = map[string]string{}
+
+	// Guards the environments and the channels they own
+	environmentsMutex sync.Mutex
+)
+
+// Create an empty environment
+// @param guildID: string - The guild owning the environment, or empty for the default environment
+// @param path: string - The environment file
+// @return *Environment - The environment
+func newEnvironment(guildID string, path string) *Environment {
+	return &Environment{
+		guildID,
+		path,
+		Folder{"", "/", map[string]*Folder{}, map[string]*File{}, []string{"*", "*"}, false, "", map[string]string{}, false, "", false, nil, []string{}, Metadata{}},
+		map[string](*SystemVariable){
+			"version": &(SystemVariable{"version", true, VERSION}), // Current version of the program
+			"time":    &(SystemVariable{"time", false, "0"}),       // Current time
+		},
+		map[string]([]string){},
+		[]string{},
+		map[string](*CronTask){},
+		[]string{},
+		MAX_CHANNELS,
+		DEFAULT_SESSIONS_PER_USER,
+	}
+}
+
+// Get the environment of a guild
+// @param guildID: string - The guild
+// @return *Environment - The environment of the guild, or the default environment
+func guildEnvironment(guildID string) *Environment {
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+	if env, ok := environments[guildID]; ok {
+		return env
+	}
+	return defaultEnvironment
+}
+
+// Get the environment a channel works in
+// @param channel: string - The channel
+// @return *Environment - The environment of the channel's guild, or the default environment
+func channelEnvironment(channel string) *Environment {
+	environmentsMutex.Lock()
+	guildID, ok := channelGuilds[channel]
+	environmentsMutex.Unlock()
+	if !ok {
+		return defaultEnvironment
+	}
+	return guildEnvironment(guildID)
+}
+
+// Remember the guild a channel belongs to
+// @param channel: string - The channel
+// @param guildID: string - The guild
+func bindChannel(channel string, guildID string) {
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+	channelGuilds[channel] = guildID
+}
+
+// Make a channel use the same guild as another one
+// @param channel: string - The new channel
+// @param from: string - The channel it was opened from
+func inheritChannel(channel string, from string) {
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+	if guildID, ok := channelGuilds[from]; ok {
+		channelGuilds[channel] = guildID
+	}
+}
+
+// Get every environment, the default one first
+// @return []*Environment - The environments
+func allEnvironments() []*Environment {
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+
+	all := []*Environment{defaultEnvironment}
+	for _, env := range environments {
+		all = append(all, env)
+	}
+	return all
+}
+
+// Determine if a channel is a focus channel of an environment
+// @param env: *Environment - The environment
+// @param channel: string - The channel
+// @return bool - True if the channel is a focus channel, false otherwise
+func isFocusChannel(env *Environment, channel string) bool {
+	for _, focus := range env.focusChannels {
+		if focus == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Send the channels using an environment back to its root directory
+// @param env: *Environment - The environment
+func resetCurrentDirectories(env *Environment) {
+	currentDirMutex.Lock()
+	channels := make([]string, 0, len(currentDir))
+	for channel := range currentDir {
+		channels = append(channels, channel)
+	}
+	currentDirMutex.Unlock()
+
+	for _, channel := range channels {
+		if channelEnvironment(channel) == env {
+			setCurrentDirectory(channel, &env.root)
+		}
+	}
+	if env == defaultEnvironment {
+		setCurrentDirectory("default", &env.root)
+	}
+}
+
+// Load the environment of a guild from the guilds file
+// Guilds missing from the file keep using the default environment
+// @param guildID: string - The guild
+// @return error - The error if the environment of the guild could not be loaded
+func loadGuildEnvironment(guildID string) error {
+
+	// Guilds show up again after reconnecting, keep their state
+	environmentsMutex.Lock()
+	_, loaded := environments[guildID]
+	environmentsMutex.Unlock()
+	if loaded {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(*guildsPath)
+	if err != nil {
+		return nil
+	}
+
+	settings, ok := gjson.ParseBytes(data).Map()[guildID]
+	if !ok {
+		return nil
+	}
+
+	env := newEnvironment(guildID, settings.Get("directory").String())
+	for _, channel := range settings.Get("focus_channels").Array() {
+		env.focusChannels = append(env.focusChannels, channel.String())
+	}
+	if settings.Get("max_channels").Exists() {
+		env.maxChannels = int(settings.Get("max_channels").Int())
+	}
+	if settings.Get("max_sessions_per_user").Exists() {
+		env.sessionsPerUser = int(settings.Get("max_sessions_per_user").Int())
+	}
+
+	err = loadEnvironment(env.path, env)
+	if err != nil {
+		return err
+	}
+
+	environmentsMutex.Lock()
+	environments[guildID] = env
+	environmentsMutex.Unlock()
+	resetCurrentDirectories(env)
+	return nil
+}
+
+// Write the settings of every provisioned guild to the guilds file
+// @return error - The error if the file could not be written
+func saveGuilds() error {
+
+	environmentsMutex.Lock()
+	settings := map[string]interface{}{}
+	for guildID, env := range environments {
+		settings[guildID] = map[string]interface{}{
+			"directory":             env.path,
+			"focus_channels":        env.focusChannels,
+			"max_channels":          env.maxChannels,
+			"max_sessions_per_user": env.sessionsPerUser,
+		}
+	}
+	environmentsMutex.Unlock()
+
+	data, _ := json.MarshalIndent(settings, "", "    ")
+	err := os.WriteFile(*guildsPath, data, 0644)
+	if err != nil {
+		return errors.New("Error: Could not save the guilds file.")
+	}
+	return nil
+}
+
+// Give a guild its own environment, copied from a template environment file
+// @param guildID: string - The guild to provision
+// @param template: string - The environment file to copy
+// @param focusChannel: string - The first focus channel of the guild
+// @return *Environment - The environment of the guild
+// @return error - The error if the guild could not be provisioned
+func provisionGuild(guildID string, template string, focusChannel string) (*Environment, error) {
+
+	environmentsMutex.Lock()
+	_, exists := environments[guildID]
+	environmentsMutex.Unlock()
+	if exists {
+		return nil, errors.New("Error: This guild already has its own environment.")
+	}
+
+	data, err := ioutil.ReadFile(template)
+	if err != nil {
+		return nil, errors.New("Error: Could not read the template \"" + template + "\".")
+	}
+
+	// Keep the environments of the guilds next to the guilds file
+	path := filepath.Join(filepath.Dir(*guildsPath), "guild-"+guildID+".json")
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return nil, errors.New("Error: Could not create the environment file \"" + path + "\".")
+	}
+
+	env := newEnvironment(guildID, path)
+	env.focusChannels = []string{focusChannel}
+	err = loadEnvironment(path, env)
+	if err != nil {
+		return nil, errors.New("Error: Could not load the template \"" + template + "\". " + err.Error())
+	}
+
+	environmentsMutex.Lock()
+	environments[guildID] = env
+	environmentsMutex.Unlock()
+	resetCurrentDirectories(env)
+
+	return env, saveGuilds()
+}
+
+// Describe the environment of a guild
+// @param env: *Environment - The environment
+// @return string - The description
+func drawEnvironment(env *Environment) string {
+
+	if env == defaultEnvironment {
+		return "This guild uses the default environment \"" + *envPath + "\".\n"
+	}
+
+	focus := append([]string{}, env.focusChannels...)
+	sort.Strings(focus)
+
+	output := fmt.Sprintf("Environment: %s\n", env.path)
+	output += fmt.Sprintf("Focus channels: %v\n", focus)
+	output += fmt.Sprintf("Max channels: %d\n", env.maxChannels)
+	output += fmt.Sprintf("Max sessions per user: %d\n", env.sessionsPerUser)
+	return output
+}
+
+// Event handler for guilds becoming available
+// Loads the environment of the guild and registers its slash commands
+func onGuildCreate(session *discordgo.Session, guild *discordgo.GuildCreate) {
+
+	err := loadGuildEnvironment(guild.ID)
+	if err != nil {
+		fmt.Println("Error: Could not load the environment of guild "+guild.ID+",", err)
+	}
+
+	_, err = session.ApplicationCommandBulkOverwrite(session.State.User.ID, guild.ID, slashCommands)
+	if err != nil {
+		fmt.Println("Error: Could not register the slash commands of guild "+guild.ID+",", err)
+	}
+}
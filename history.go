@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// Default max number of versions kept for each file
+	DEFAULT_HISTORY_SIZE = 10
+
+	// Number of unchanged lines shown around the changes of a diff
+	DIFF_CONTEXT_LINES = 3
+
+	// Max number of line pairs compared by a diff, past which the files are only reported as different
+	MAX_DIFF_CELLS = 4000000
+)
+
+// Structure of a version of a file
+// The contents of the latest version are the contents of the file itself
+type Revision struct {
+	version int
+	author  string
+	time    time.Time
+	message string
+	data    string
+	cache   string
+}
+
+// Keep the current contents of a file in its history, before they are replaced
+// Files without history get a first version made from their metadata
+// @param file: *File - The file about to be written
+func keepRevision(file *File) {
+	if len(file.history) == 0 {
+		file.history = append(file.history, Revision{file.meta.version, file.meta.author, file.meta.modified, "", "", ""})
+	}
+	last := &file.history[len(file.history)-1]
+	last.data, last.cache = file.data, file.cache
+}
+
+// Record the new version of a file written
+// The oldest versions are dropped past "history_max_revisions" in the configuration file
+// @param file: *File - The file written, its metadata already updated
+// @param author: string - The user ID of the author of the change, or empty if unknown
+// @param message: string - What the change was
+func addRevision(file *File, author string, message string) {
+
+	file.history = append(file.history, Revision{file.meta.version, author, file.meta.modified, message, "", ""})
+
+	limit := configInt("history_max_revisions", DEFAULT_HISTORY_SIZE)
+	if limit < 1 {
+		limit = 1
+	}
+	if len(file.history) > limit {
+		file.history = append([]Revision{}, file.history[len(file.history)-limit:]...)
+	}
+}
+
+// Find a version in the history of a file
+// @param file: *File - The file
+// @param rev: string - The version, like "3" or "v3"
+// @return int - The index of the version in the history
+// @return error - The error if the version is not kept
+func findRevision(file *File, rev string) (int, error) {
+
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(rev), "v"))
+	if err == nil {
+		for i, revision := range file.history {
+			if revision.version == version {
+				return i, nil
+			}
+		}
+	}
+
+	return -1, errors.New("Error: Could not find the version \"" + rev + "\" of \"" + file.path + file.name + "\". Use \"log\" to list the versions kept.")
+}
+
+// Read the contents of a version of a file
+// @param file: *File - The file
+// @param index: int - The index of the version in the history
+// @return []byte - The contents of the version
+// @return error - The error if the contents could not be read
+func revisionContents(file *File, index int) ([]byte, error) {
+
+	if index == len(file.history)-1 {
+		return readFile(file)
+	}
+
+	revision := file.history[index]
+	if revision.cache != "" {
+		return loadBlob(revision.cache, "")
+	}
+	return []byte(revision.data), nil
+}
+
+// Get the file whose history is used
+// @param path: string - The path of the file
+// @param channel: string - The channel used to resolve relative paths
+// @param context: *HookContext - Who is reading the contents of the file, or nil if they are not shown
+// @return *File - The file
+// @return error - The error if the file cannot be accessed or has no history
+func historyFile(path string, channel string, context *HookContext) (*File, error) {
+
+	var file *File
+	var err error
+	if context != nil {
+		file, err = openFile(path, channel, context)
+	} else {
+		file, err = getFile(path, true, channel)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if file.mounted != nil {
+		return nil, errors.New("Error: The files of a mount have no history.")
+	}
+	if len(file.history) == 0 {
+		return nil, errors.New("Error: \"" + file.path + file.name + "\" has no history yet.")
+	}
+
+	return file, nil
+}
+
+// Draw the versions kept for a file, the latest first
+// @param file: *File - The file
+// @return string - One line per version: version, time, author and message
+func drawLog(file *File) string {
+
+	var output bytes.Buffer
+	writer := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+
+	for i := len(file.history) - 1; i >= 0; i-- {
+		revision := file.history[i]
+		message := revision.message
+		if i == len(file.history)-1 {
+			message += " (current)"
+		}
+		fmt.Fprintf(writer, "v%d\t%s\t%s\t%s\n", revision.version, _drawTime(revision.time), _drawAuthor(revision.author), strings.TrimSpace(message))
+	}
+
+	writer.Flush()
+	return output.String()
+}
+
+// Draw the differences between two versions of a file, line by line
+// @param before: []byte - The contents of the older version
+// @param after: []byte - The contents of the newer version
+// @param beforeName: string - The name of the older version
+// @param afterName: string - The name of the newer version
+// @return string - The changed lines, "-" for removed ones and "+" for added ones, with some context
+func drawDiff(before []byte, after []byte, beforeName string, afterName string) string {
+
+	if bytes.Equal(before, after) {
+		return "No difference between " + beforeName + " and " + afterName + ".\n"
+	}
+	if isBinary(before) || isBinary(after) {
+		return "Binary versions " + beforeName + " and " + afterName + " differ.\n"
+	}
+
+	a := strings.Split(string(before), "\n")
+	b := strings.Split(string(after), "\n")
+	if len(a)*len(b) > MAX_DIFF_CELLS {
+		return "Versions " + beforeName + " and " + afterName + " differ, but are too large to compare.\n"
+	}
+
+	// Find the longest common subsequence of lines, from the end
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	// List every line, marked as kept, removed or added
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	// Only keep the changes and the lines around them
+	output := "--- " + beforeName + "\n+++ " + afterName + "\n"
+	skipped := false
+	for index, line := range lines {
+		near := false
+		for k := index - DIFF_CONTEXT_LINES; k <= index+DIFF_CONTEXT_LINES; k++ {
+			if k >= 0 && k < len(lines) && lines[k][0] != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			skipped = true
+			continue
+		}
+		if skipped {
+			output += "...\n"
+			skipped = false
+		}
+		output += line + "\n"
+	}
+
+	return output
+}
+
+// Draw the differences between a version of a file and its current contents
+// @param file: *File - The file
+// @param rev: string - The version to compare, or empty for the previous one
+// @return string - The differences
+// @return error - The error if the version cannot be read
+func diffFile(file *File, rev string) (string, error) {
+
+	index := len(file.history) - 2
+	if rev != "" {
+		found, err := findRevision(file, rev)
+		if err != nil {
+			return "", err
+		}
+		index = found
+	}
+	if index < 0 {
+		return "", errors.New("Error: \"" + file.path + file.name + "\" has no earlier version.")
+	}
+
+	current := len(file.history) - 1
+	before, err := revisionContents(file, index)
+	if err != nil {
+		return "", err
+	}
+	after, err := revisionContents(file, current)
+	if err != nil {
+		return "", err
+	}
+
+	return drawDiff(before, after, fmt.Sprintf("v%d", file.history[index].version), fmt.Sprintf("v%d", file.history[current].version)), nil
+}
+
+// Bring back the contents of a version of a file, as a new version
+// @param path: string - The path of the file
+// @param rev: string - The version to bring back
+// @param channel: string - The channel used to resolve relative paths
+// @param author: string - The user ID of the author of the change, or empty if unknown
+// @return *File - The file written
+// @return error - The error if the version cannot be brought back
+func revertFile(path string, rev string, channel string, author string) (*File, error) {
+
+	file, err := historyFile(path, channel, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := findRevision(file, rev)
+	if err != nil {
+		return nil, err
+	}
+	if index == len(file.history)-1 {
+		return nil, fmt.Errorf("Error: \"%s\" is already at version %d.", file.path+file.name, file.history[index].version)
+	}
+
+	revision := file.history[index]
+	message := fmt.Sprintf("Reverted to v%d", revision.version)
+
+	// Cached contents are shared rather than copied
+	if revision.cache != "" {
+		file, err = writeFile(path, "", channel, author, message)
+		if err != nil {
+			return nil, err
+		}
+		file.cache = revision.cache
+		file.hash = ""
+		return file, nil
+	}
+
+	return writeFile(path, revision.data, channel, author, message)
+}
+
+// Load the history of a file
+// @param element: gjson.Result - The file
+// @return []Revision - The versions kept, oldest first
+func loadHistory(element gjson.Result) []Revision {
+
+	history := []Revision{}
+	for _, value := range element.Get("history").Array() {
+		revision := Revision{int(value.Get("version").Int()), value.Get("author").String(), time.Time{}, value.Get("message").String(), value.Get("data").String(), value.Get("cache").String()}
+		if value.Get("time").Exists() {
+			parsed, err := time.ParseInLocation(METADATA_TIME_FORMAT, value.Get("time").String(), time.Local)
+			if err != nil {
+				fmt.Printf("Exception encountered while loading time \"%s\". Invalid time format. Expected YYYY-MM-DD HH:MM:SS.\n", value.Get("time").String())
+			}
+			revision.time = parsed
+		}
+		history = append(history, revision)
+	}
+
+	return history
+}
+
+// Save the history of a file
+// The contents of the latest version are not repeated, they are the contents of the file
+// @param history: []Revision - The versions kept
+// @return string - The history followed by a comma, or empty if there is none
+func _saveHistory(history []Revision) string {
+
+	if len(history) == 0 {
+		return ""
+	}
+
+	revisions := []string{}
+	for i, revision := range history {
+		output := "{\"version\": " + fmt.Sprint(revision.version) + ","
+		if !revision.time.IsZero() {
+			output += "\"time\": \"" + revision.time.Format(METADATA_TIME_FORMAT) + "\","
+		}
+		if revision.author != "" {
+			output += "\"author\": " + jsonString(revision.author) + ","
+		}
+		if revision.message != "" {
+			output += "\"message\": " + jsonString(revision.message) + ","
+		}
+		if i < len(history)-1 {
+			if revision.cache != "" {
+				output += "\"cache\": " + jsonString(revision.cache) + ","
+			} else {
+				output += "\"data\": " + jsonString(revision.data) + ","
+			}
+		}
+		revisions = append(revisions, strings.TrimSuffix(output, ",")+"}")
+	}
+
+	return "\"history\": [" + strings.Join(revisions, ", ") + "],"
+}
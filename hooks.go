@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tidwall/gjson"
+)
+
+// Events that can trigger the hooks of a file or folder
+var hookEvents = []string{"onRead", "onUnlock", "onEnter", "onFirstUnlock"}
+
+// Structure describing who triggered a hook and where
+type HookContext struct {
+	session *discordgo.Session
+	member  *discordgo.Member
+	guildID string
+	userID  string
+	channel string
+}
+
+// Load the hooks of an element of the environment
+// @param element: gjson.Result - The file or folder element
+// @return map[string]string - The actions of each event, as raw JSON arrays
+func _loadHooks(element gjson.Result) map[string]string {
+	hooks := make(map[string]string)
+	for _, event := range hookEvents {
+		if element.Get(event).IsArray() {
+			hooks[event] = element.Get(event).Raw
+		}
+	}
+	return hooks
+}
+
+// Save the hooks of an element of the environment
+// @param hooks: map[string]string - The actions of each event
+// @return string - The hooks as JSON members, each followed by a comma
+func _saveHooks(hooks map[string]string) string {
+	output := ""
+	for _, event := range hookEvents {
+		if actions, ok := hooks[event]; ok {
+			output += "\"" + event + "\": " + actions + ","
+		}
+	}
+	return output
+}
+
+// Run the actions bound to an event
+// @param hooks: map[string]string - The hooks of the file or folder
+// @param event: string - The event that occurred
+// @param context: *HookContext - Who triggered the event and where
+func fireHook(hooks map[string]string, event string, context *HookContext) {
+
+	actions, ok := hooks[event]
+	if !ok {
+		return
+	}
+
+	for _, action := range gjson.Parse(actions).Array() {
+		err := _runHookAction(action, context)
+		if err != nil {
+			fmt.Printf("Exception encountered while running hook \"%s\". %s\n", event, err.Error())
+		}
+	}
+}
+
+// Run a single hook action
+// @param action: gjson.Result - The action to run
+// @param context: *HookContext - Who triggered the event and where
+// @return error - The error if any
+func _runHookAction(action gjson.Result, context *HookContext) error {
+
+	env := channelEnvironment(context.channel)
+
+	// Set variables
+	if action.Get("set").Exists() {
+		for name, value := range action.Get("set").Map() {
+			var err error
+			if systemVariableExists(name, env) {
+				err = setSystemVariable(name, value.String(), env)
+			} else {
+				err = createSystemVariable(name, value.String(), env)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Print a message in the current channel
+	if action.Get("echo").Exists() {
+		hookEcho(context, context.channel, action.Get("echo").String())
+	}
+
+	// Print a message in every focus channel
+	if action.Get("post").Exists() {
+		if context.session == nil {
+			hookEcho(context, context.channel, action.Get("post").String())
+		} else {
+			for _, channel := range env.focusChannels {
+				hookEcho(context, channel, action.Get("post").String())
+			}
+		}
+	}
+
+	// Subscribe the user to a role
+	if action.Get("grant").Exists() && context.session != nil {
+		err := subscribeToRole(context.session, context.member, context.guildID, context.userID, action.Get("grant").String())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Show a hidden file or folder
+	if action.Get("reveal").Exists() {
+		path := action.Get("reveal").String()
+		if file, err := getFile(path, false, context.channel); err == nil {
+			file.hidden = false
+		} else if folder, err := getDirectory(path, false, context.channel); err == nil {
+			folder.hidden = false
+		} else {
+			return err
+		}
+	}
+
+	// Run a Kode program stored in the environment
+	if action.Get("run").Exists() && context.session != nil {
+		path := action.Get("run").String()
+		file, err := getFile(path, false, context.channel)
+		if err != nil {
+			return err
+		}
+
+		program, err := readFile(file)
+		if err != nil {
+			return err
+		}
+
+		host := &KodeHost{context.session, context.member, context.guildID, context.channel}
+		_, err = startJob(context.session, context.channel, "run "+path, program, host)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Print a message triggered by a hook
+// @param context: *HookContext - Who triggered the event and where
+// @param channel: string - The channel to print the message to
+// @param message: string - The message to print
+func hookEcho(context *HookContext, channel string, message string) {
+	if context.session == nil {
+		fmt.Println(message)
+		return
+	}
+	echo(context.session, channel, message, COLOR_BLUE)
+}
+
+// Run the hooks of a folder or file that was just unlocked
+// The first unlock is remembered so "onFirstUnlock" only ever runs once
+// @param hooks: map[string]string - The hooks of the folder or file
+// @param unlockedOnce: *bool - Whether the folder or file was already unlocked
+// @param context: *HookContext - Who triggered the event and where
+func fireUnlockHooks(hooks map[string]string, unlockedOnce *bool, context *HookContext) {
+	if !*unlockedOnce {
+		*unlockedOnce = true
+		fireHook(hooks, "onFirstUnlock", context)
+	}
+	fireHook(hooks, "onUnlock", context)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Possible states of a background job
+const (
+	JOB_RUNNING = "running"
+	JOB_DONE    = "done"
+	JOB_FAILED  = "failed"
+	JOB_KILLED  = "killed"
+)
+
+// Structure of a program running in the background
+type Job struct {
+	id      int
+	channel string
+	command string
+	status  string
+	started time.Time
+	cmd     *exec.Cmd
+	host    *KodeHost
+	output  func(string)
+	done    chan bool
+}
+
+var (
+	// Background jobs of each channel
+	jobs map[string]([]*Job) = map[string]([]*Job){}
+
+	// Identifier given to the next job created
+	nextJobId = 1
+
+	// Guards the job table, since jobs finish outside of the message handler
+	jobsMutex sync.Mutex
+)
+
+// Start a Kode program and stream its output line by line
+// @param program: []byte - The Kode source code to run
+// @param output: func(string) - Called with every line printed by the program
+// @param host: *KodeHost - Answers the requests of the program, or nil to keep it isolated
+// @return *exec.Cmd - The started process
+// @return chan error - Receives the result of the process once it has ended
+// @return error - The error if the program could not be started
+func startKode(program []byte, output func(string), host *KodeHost) (*exec.Cmd, chan error, error) {
+
+	program = []byte(string(program) + "\nexit\n")
+
+	cmd := exec.Command("kode", "-runStdIn")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, errors.New("Error: Failed to create stdin pipe.")
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, errors.New("Error: Failed to create stdout pipe.")
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, nil, errors.New("Error: Failed to start the Kode interpreter.")
+	}
+
+	// Send the program to the interpreter
+	// The input stays open while the host answers the program's requests
+	var stdinMutex sync.Mutex
+	go func() {
+		stdinMutex.Lock()
+		defer stdinMutex.Unlock()
+
+		if _, err := stdin.Write(program); err != nil {
+			output("Error: Failed to write to stdin.")
+		}
+		if host == nil {
+			stdin.Close()
+		}
+	}()
+
+	result := make(chan error, 1)
+
+	// Forward the program output until it exits
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "exit" {
+				break
+			}
+
+			// Answer requests made to KuriOS
+			if host != nil && strings.HasPrefix(line, KODE_HOST_PREFIX) {
+				response := host.handle(strings.TrimPrefix(line, KODE_HOST_PREFIX))
+				stdinMutex.Lock()
+				stdin.Write([]byte(response + "\n"))
+				stdinMutex.Unlock()
+				continue
+			}
+
+			output(line)
+		}
+		if host != nil {
+			stdin.Close()
+		}
+
+		// Drain whatever is left so the process can end
+		io.Copy(ioutil.Discard, stdout)
+
+		result <- cmd.Wait()
+	}()
+
+	return cmd, result, nil
+}
+
+// Determine if a Kode program ended because it crashed
+// @param err: error - The result of the process
+// @return bool - True if the program crashed, false otherwise
+func kodeCrashed(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err.Error() != "exit status 1" && err.Error() != "exit status 0xc000013a"
+}
+
+// Start a Kode program as a background job of a channel
+// @param session: *discordgo.Session - The discord session to report to
+// @param channel: string - The channel that started the job
+// @param command: string - The command line shown in the job table
+// @param program: []byte - The Kode source code to run
+// @param host: *KodeHost - Answers the requests of the program
+// @return *Job - The job created
+// @return error - The error if the program could not be started
+func startJob(session *discordgo.Session, channel string, command string, program []byte, host *KodeHost) (*Job, error) {
+
+	// Reserve an identifier for the job
+	jobsMutex.Lock()
+	job := &Job{nextJobId, channel, command, JOB_RUNNING, time.Now(), nil, host, nil, make(chan bool)}
+	nextJobId++
+	jobsMutex.Unlock()
+
+	// The output goes to the command waiting for the job in the foreground, if any
+	cmd, result, err := startKode(program, func(line string) {
+		jobsMutex.Lock()
+		output := job.output
+		jobsMutex.Unlock()
+		if output != nil {
+			output(line)
+			return
+		}
+		echo(session, jobOutputChannel(job), "["+strconv.Itoa(job.id)+"] "+line, COLOR_WHITE)
+	}, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register the job
+	jobsMutex.Lock()
+	job.cmd = cmd
+	jobs[channel] = append(jobs[channel], job)
+	jobsMutex.Unlock()
+
+	// Notify the channel once the job is over
+	go func() {
+		err := <-result
+
+		jobsMutex.Lock()
+		if job.status == JOB_RUNNING {
+			if kodeCrashed(err) {
+				job.status = JOB_FAILED
+			} else {
+				job.status = JOB_DONE
+			}
+		}
+		status := job.status
+		jobsMutex.Unlock()
+
+		color := COLOR_GREEN
+		if status != JOB_DONE {
+			color = COLOR_RED
+		}
+		echo(session, jobOutputChannel(job), fmt.Sprintf("[%d] %s\t%s", job.id, status, job.command), color)
+
+		close(job.done)
+	}()
+
+	return job, nil
+}
+
+// Get the channel a job should currently report to
+// Jobs of a closed channel report to the focus channel it was opened from
+// @param job: *Job - The job
+// @return string - The channel ID
+func jobOutputChannel(job *Job) string {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	return job.channel
+}
+
+// Bring a job to the foreground until it ends
+// @param job: *Job - The job
+// @param output: func(string) - Called with every line the job prints from now on
+func foregroundJob(job *Job, output func(string)) {
+	jobsMutex.Lock()
+	job.output = output
+	jobsMutex.Unlock()
+
+	<-job.done
+}
+
+// Get a job of a channel by its identifier
+// @param channel: string - The channel owning the job
+// @param id: string - The job identifier, optionally prefixed by '%'
+// @return *Job - The job
+// @return error - The error if the job does not exist
+func getJob(channel string, id string) (*Job, error) {
+
+	if len(id) > 0 && id[0] == '%' {
+		id = id[1:]
+	}
+
+	jobId, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, errors.New("Error: Invalid job identifier \"" + id + "\".")
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	for _, job := range jobs[channel] {
+		if job.id == jobId {
+			return job, nil
+		}
+	}
+
+	return nil, errors.New("Error: Could not find the job " + id + ".")
+}
+
+// Draw the job table of a channel
+// @param channel: string - The channel to list the jobs of
+// @return string - The job table
+func drawJobs(channel string) string {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	if len(jobs[channel]) == 0 {
+		return "No jobs."
+	}
+
+	output := ""
+	for _, job := range jobs[channel] {
+		output += fmt.Sprintf("[%d] %-8s %s (%s)\n", job.id, job.status, job.command, time.Since(job.started).Round(time.Second))
+	}
+
+	return output
+}
+
+// Stop a running job
+// @param job: *Job - The job to stop
+// @return error - The error if the job could not be stopped
+func killJob(job *Job) error {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	if job.status != JOB_RUNNING {
+		return errors.New("Error: The job " + strconv.Itoa(job.id) + " is not running.")
+	}
+
+	err := job.cmd.Process.Kill()
+	if err != nil {
+		return errors.New("Error: Could not kill the job " + strconv.Itoa(job.id) + ".")
+	}
+
+	job.status = JOB_KILLED
+	return nil
+}
+
+// Remove all finished jobs from the table of a channel
+// @param channel: string - The channel to clean up
+func pruneJobs(channel string) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	running := []*Job{}
+	for _, job := range jobs[channel] {
+		if job.status == JOB_RUNNING {
+			running = append(running, job)
+		}
+	}
+
+	if len(running) == 0 {
+		delete(jobs, channel)
+	} else {
+		jobs[channel] = running
+	}
+}
+
+// Hand the jobs of a closed channel over to another channel
+// @param from: string - The channel being closed
+// @param to: string - The channel taking over the jobs
+// @return int - The number of running jobs moved
+func reparentJobs(from string, to string) int {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	moved := 0
+	for _, job := range jobs[from] {
+		if job.status == JOB_RUNNING {
+			job.channel = to
+			if job.host != nil {
+				job.host.channel = to
+			}
+			jobs[to] = append(jobs[to], job)
+			moved++
+		}
+	}
+	delete(jobs, from)
+
+	return moved
+}
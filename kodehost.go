@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Prefix of the lines a Kode program prints to talk to KuriOS
+// A request is a single line such as `::kurios read "/My Folder/My File"`,
+// `::kurios write <path> <data>`, `::kurios get <name>` or `::kurios set <name> <value>`.
+// KuriOS answers on the program's standard input with a single line,
+// either "::ok <value>" or "::error <message>". Newlines and backslashes
+// in values are escaped as "\n" and "\\" in both directions.
+const KODE_HOST_PREFIX = "::kurios "
+
+// Structure describing who a Kode program is running for
+type KodeHost struct {
+	session *discordgo.Session
+	member  *discordgo.Member
+	guildID string
+	channel string
+}
+
+// Answer a request made by a Kode program
+// @param request: string - The request line, without its prefix
+// @return string - The response line to send back to the program
+func (host *KodeHost) handle(request string) string {
+
+	args, err := parseCommandLine(request)
+	if err != nil || len(args) == 0 {
+		return "::error " + escapeKodeValue("Error: Invalid request format.")
+	}
+
+	value, err := host.execute(args)
+	if err != nil {
+		return "::error " + escapeKodeValue(err.Error())
+	}
+
+	return "::ok " + escapeKodeValue(value)
+}
+
+// Get the channel the requests of a program are resolved in
+// Background jobs are handed over to another channel when theirs is closed
+// @return string - The channel ID
+func (host *KodeHost) currentChannel() string {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	return host.channel
+}
+
+// Execute a request made by a Kode program
+// @param args: []string - The parsed request
+// @return string - The value returned to the program
+// @return error - The error if any
+func (host *KodeHost) execute(args []string) (string, error) {
+
+	channel := host.currentChannel()
+
+	switch args[0] {
+
+	// Read the contents of a file
+	case "read":
+
+		if !host.allowed("cat") {
+			return "", errors.New("Error: You do not have permission to read files.")
+		}
+
+		if len(args) < 2 {
+			return "", errors.New("Error: Expecting \"read <path>\".")
+		}
+
+		// Programs read files the same way as cat, with their hooks
+		file, err := openFile(args[1], channel, &HookContext{host.session, host.member, host.guildID, host.userID(), channel})
+		if err != nil {
+			return "", err
+		}
+
+		data, err := readFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		return string(data), nil
+
+	// Write the contents of a file, creating it if needed
+	case "write":
+
+		if !host.allowed("write") {
+			return "", errors.New("Error: You do not have permission to write files.")
+		}
+
+		if len(args) < 3 {
+			return "", errors.New("Error: Expecting \"write <path> <data>\".")
+		}
+
+		_, err := writeFile(args[1], unescapeKodeValue(args[2]), channel, host.userID(), "Written by a Kode program")
+		if err != nil {
+			return "", err
+		}
+
+		return "", nil
+
+	// Get the value of a variable
+	case "get":
+
+		if !host.allowed("get") {
+			return "", errors.New("Error: You do not have permission to get variables.")
+		}
+
+		if len(args) < 2 {
+			return "", errors.New("Error: Expecting \"get <name>\".")
+		}
+
+		variable, err := getVariable(args[1], channel)
+		if err != nil {
+			return "", err
+		}
+
+		return variable.Value, nil
+
+	// Set the value of a variable, creating it if needed
+	case "set":
+
+		if !host.allowed("set") {
+			return "", errors.New("Error: You do not have permission to set variables.")
+		}
+
+		if len(args) < 3 {
+			return "", errors.New("Error: Expecting \"set <name> <value>\".")
+		}
+
+		value := unescapeKodeValue(args[2])
+		if !variableExists(args[1], channel) {
+			return "", createVariable(args[1], value, channel)
+		}
+
+		return "", setVariable(args[1], value, channel)
+
+	default:
+		return "", errors.New("Error: Unknown request \"" + args[0] + "\".")
+	}
+}
+
+// Check a permission of the user running the program
+// @param permission: string - The permission to check
+// @return bool - True if the user has the permission, false otherwise
+func (host *KodeHost) allowed(permission string) bool {
+	return HasPermission(host.session, host.member, host.guildID, permission)
+}
+
+// Get the user a program is running for
+// @return string - The user ID, or empty if unknown
+func (host *KodeHost) userID() string {
+	if host.member != nil && host.member.User != nil {
+		return host.member.User.ID
+	}
+	return ""
+}
+
+// Escape a value so it fits on a single line
+// @param value: string - The value to escape
+// @return string - The escaped value
+func escapeKodeValue(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	return strings.ReplaceAll(value, "\n", "\\n")
+}
+
+// Restore a value escaped by a Kode program
+// @param value: string - The escaped value
+// @return string - The original value
+func unescapeKodeValue(value string) string {
+	output := ""
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' {
+				output += "\n"
+			} else {
+				output += string(value[i])
+			}
+			continue
+		}
+		output += string(value[i])
+	}
+	return output
+}
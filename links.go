@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"path"
+	"strings"
+)
+
+// Max number of links followed while resolving a path, past which a cycle is assumed
+const MAX_LINK_DEPTH = 16
+
+// Get the absolute path a link leads to
+// Relative targets are resolved from the directory holding the link
+// @param link: *File - The link
+// @return string - The absolute path of the target
+func linkTarget(link *File) string {
+	target := link.link
+	if !strings.HasPrefix(target, "/") {
+		target = link.path + target
+	}
+	return path.Clean("/" + target)
+}
+
+// Create a link to a file or a directory
+// The target does not need to exist yet
+// @param target: string - The path the link leads to, absolute or relative to the link
+// @param linkPath: string - The path of the new link
+// @param channel: string - The channel used to resolve relative paths
+// @param author: string - The user ID of the author of the link, or empty if unknown
+// @return *File - The link created
+// @return error - The error if the link cannot be created
+func createLink(target string, linkPath string, channel string, author string) (*File, error) {
+
+	if strings.TrimSpace(target) == "" {
+		return nil, errors.New("Error: The target of the link cannot be empty.")
+	}
+
+	// Find the directory holding the new link
+	dirPath, name := splitPath(normalizePath(linkPath, channel))
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("Error: Invalid link name \"" + linkPath + "\".")
+	}
+
+	parent, err := getDirectory(dirPath, false, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	expandFolder(parent)
+	if (*parent).mounted != nil {
+		return nil, errors.New("Error: Links cannot be created inside a mount.")
+	}
+	if _, ok := (*parent).folders[name]; ok {
+		return nil, errors.New("Error: \"" + name + "\" already exists.")
+	}
+	if _, ok := (*parent).files[name]; ok {
+		return nil, errors.New("Error: \"" + name + "\" already exists.")
+	}
+
+	link := &File{name, (*parent).path + (*parent).name + "/", "", "", []string{"*", "*"}, false, "", map[string]string{}, false, "", false, "", nil, target, newMetadata(author), nil}
+	if parent == &channelEnvironment(channel).root {
+		link.path = "/"
+	}
+	(*parent).files[name] = link
+
+	return link, nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// Compare two names the way people expect them to be sorted
+// Numbers are compared by value and letters without case, so "file2" comes before "File10"
+// @param a: string - The first name
+// @param b: string - The second name
+// @return bool - True if the first name comes first, false otherwise
+func naturalLess(a string, b string) bool {
+
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+
+		// Compare whole numbers by their value
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			startA, startB := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			numberA := strings.TrimLeft(string(ra[startA:i]), "0")
+			numberB := strings.TrimLeft(string(rb[startB:j]), "0")
+			if len(numberA) != len(numberB) {
+				return len(numberA) < len(numberB)
+			}
+			if numberA != numberB {
+				return numberA < numberB
+			}
+			continue
+		}
+
+		la, lb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if la != lb {
+			return la < lb
+		}
+		i++
+		j++
+	}
+
+	if len(ra)-i != len(rb)-j {
+		return len(ra)-i < len(rb)-j
+	}
+
+	// Names only differing by case or leading zeros still get a stable order
+	return a < b
+}
+
+// Determine if a name comes before another in a directory
+// Names listed in the order of the directory come first, in that order, then the others in natural order
+// @param order: []string - The order of the directory
+// @param a: string - The first name
+// @param b: string - The second name
+// @return bool - True if the first name comes first, false otherwise
+func _listedBefore(order []string, a string, b string) bool {
+
+	indexA, indexB := len(order), len(order)
+	for i, name := range order {
+		if name == a && indexA == len(order) {
+			indexA = i
+		}
+		if name == b && indexB == len(order) {
+			indexB = i
+		}
+	}
+
+	if indexA != indexB {
+		return indexA < indexB
+	}
+	return naturalLess(a, b)
+}
+
+// Sort the folders of a directory
+// @param directory: *Folder - The directory holding the folders
+// @param folders: []*Folder - The folders to sort
+func sortFolders(directory *Folder, folders []*Folder) {
+	sort.SliceStable(folders, func(i, j int) bool {
+		return _listedBefore(directory.order, folders[i].name, folders[j].name)
+	})
+}
+
+// Sort the files of a directory
+// @param directory: *Folder - The directory holding the files
+// @param files: []*File - The files to sort
+func sortFiles(directory *Folder, files []*File) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return _listedBefore(directory.order, files[i].name, files[j].name)
+	})
+}
+
+// Get the size of the contents of a file, without reading them
+// @param file: *File - The file
+// @return int64 - The size in bytes, or -1 if unknown
+func fileSize(file *File) int64 {
+
+	if file.mounted != nil {
+		return file.mounted.mount.size(file.mounted.entry)
+	}
+
+	if file.cache != "" {
+		cachePath, err := resolveCachePath(file.cache)
+		if err != nil {
+			return -1
+		}
+		info, err := os.Stat(cachePath)
+		if err != nil {
+			return -1
+		}
+		return info.Size()
+	}
+
+	return int64(len(file.data))
+}
+
+// Parse the arguments of the ls command
+// Flags can be combined, like "-la"
+// @param commands: []string - The command line
+// @return string - The directory to list, or empty for the current directory
+// @return string - The flags given: "a" for hidden elements, "l" for the long format, "1" for one level
+// @return error - The error if a flag is unknown
+func parseLsArgs(commands []string) (string, string, error) {
+
+	target, flags := "", ""
+	for _, argument := range commands[1:] {
+		if len(argument) > 1 && strings.HasPrefix(argument, "-") {
+			for _, flag := range argument[1:] {
+				if !strings.ContainsRune("al1", flag) {
+					return "", "", errors.New("Error: Unknown option \"-" + string(flag) + "\". Expecting \"ls [-a] [-l] [-1] [path]\".")
+				}
+				flags += string(flag)
+			}
+		} else {
+			target = argument
+		}
+	}
+
+	return target, flags, nil
+}
+
+// Find the directory listed by ls, and the glob filtering its elements
+// A path whose last name is a glob, like "docs/*.txt", lists the matching elements of its directory
+// @param target: string - The path given to ls, or empty for the current directory
+// @param channel: string - The channel used to resolve relative paths
+// @return *Folder - The directory to list
+// @return string - The glob the names must match, or empty to list everything
+// @return error - The error if the directory cannot be accessed
+func listingTarget(target string, channel string) (*Folder, string, error) {
+
+	if target == "" {
+		return getCurrentDirectory(channel), "", nil
+	}
+
+	directory, err := getDirectory(target, true, channel)
+	if err == nil {
+		return directory, "", nil
+	}
+
+	dirPath, glob := splitPath(normalizePath(target, channel))
+	if !strings.ContainsAny(glob, "*?[") {
+		return nil, "", err
+	}
+	if _, matchErr := path.Match(glob, ""); matchErr != nil {
+		return nil, "", errors.New("Error: Invalid pattern \"" + glob + "\".")
+	}
+
+	directory, err = getDirectory(dirPath, false, channel)
+	return directory, glob, err
+}
+
+// Keep the entries of a listing whose name matches a glob, and only show one level if asked
+// @param tree: *TreeEntry - The listing
+// @param glob: string - The glob the names must match, or empty to keep everything
+// @param oneLevel: bool - Whether the contents of the folders are left out
+func trimListing(tree *TreeEntry, glob string, oneLevel bool) {
+
+	kept := []*TreeEntry{}
+	for _, child := range tree.children {
+		if matched, _ := path.Match(glob, child.name); glob != "" && !matched {
+			continue
+		}
+		if oneLevel && child.folder {
+			child.children = []*TreeEntry{}
+			child.truncated = false
+		}
+		kept = append(kept, child)
+	}
+	tree.children = kept
+}
+
+// Draw the elements of a directory with their details
+// @param directory: *Folder - The directory to list
+// @param glob: string - The glob the names must match, or empty to list everything
+// @param viewer: *Viewer - Who is looking at the directory
+// @return string - One line per element: type, state, size, version, modification time, availability window, author and name
+func drawLongListing(directory *Folder, glob string, viewer *Viewer) string {
+
+	var output bytes.Buffer
+	writer := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+
+	matches := func(name string) bool {
+		matched, _ := path.Match(glob, name)
+		return glob == "" || matched
+	}
+
+	count := 0
+	for _, folder := range visibleFolders(directory, viewer) {
+		if matches(folder.name) {
+			fmt.Fprintf(writer, "d\t%s\t-\t%s\t%s\t%s\t%s\t%s/\n", nodeState(folder.availableBetween, folder.locked), _drawVersion(folder.meta), _drawTime(folder.meta.modified), _drawWindow(folder.availableBetween), _drawAuthor(folder.meta.author), folder.name)
+			count++
+		}
+	}
+
+	for _, file := range visibleFiles(directory, viewer) {
+		if !matches(file.name) {
+			continue
+		}
+		if file.link != "" {
+			fmt.Fprintf(writer, "l\t%s\t-\t%s\t%s\t%s\t%s\t%s -> %s\n", nodeState(file.availableBetween, file.locked), _drawVersion(file.meta), _drawTime(file.meta.modified), _drawWindow(file.availableBetween), _drawAuthor(file.meta.author), file.name, file.link)
+		} else {
+			size := "?"
+			if known := fileSize(file); known >= 0 {
+				size = fmt.Sprint(known)
+			}
+			fmt.Fprintf(writer, "f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", nodeState(file.availableBetween, file.locked), size, _drawVersion(file.meta), _drawTime(file.meta.modified), _drawWindow(file.availableBetween), _drawAuthor(file.meta.author), file.name)
+		}
+		count++
+	}
+
+	writer.Flush()
+	return fmt.Sprintf("total %d\n", count) + output.String()
+}
+
+// Draw an availability window
+// @param availableBetween: []string - The window
+// @return string - "always", or the start and end of the window
+func _drawWindow(availableBetween []string) string {
+	if availableBetween[0] == "*" && availableBetween[1] == "*" {
+		return "always"
+	}
+	return availableBetween[0] + " .. " + availableBetween[1]
+}
+
+// Draw the author of an element
+// @param author: string - The user ID of the author
+// @return string - The author, or "-" if there is none
+func _drawAuthor(author string) string {
+	if author == "" {
+		return "-"
+	}
+	return author
+}
+
+// Draw the version of an element
+// @param meta: Metadata - The metadata of the element
+// @return string - The version, like "v3", or "-" if unknown
+func _drawVersion(meta Metadata) string {
+	if meta.version <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("v%d", meta.version)
+}
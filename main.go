@@ -8,10 +8,11 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -22,36 +23,29 @@ var (
 	// Current version of the program
 	VERSION = "0.0.1"
 
-	// Max number of channels
+	// Default max number of channels opened as sessions in a guild
 	MAX_CHANNELS = 5
 
 	// Contents of the configuration file
 	// Contains discord token, focus channel, and other configurations
 	config map[string]interface{}
 
-	// Root directory folder
-	root Folder
-
-	// Open channels
-	openChannels []string
-
 	// Current directory
-	currentDir map[string](*Folder)
+	currentDir map[string](*Folder) = map[string](*Folder){}
 
-	// System variables
-	systemVariables map[string](*SystemVariable) = map[string](*SystemVariable){
-		"version": &(SystemVariable{"version", true, VERSION}), // Current version of the program
-		"time":    &(SystemVariable{"time", false, "0"}),       // Current time
-	}
+	// Guards the current directories, since sessions and scheduled tasks change them outside of the message handler
+	currentDirMutex sync.Mutex
 
-	// User permissions
-	systemPermissions   map[string]([]string) = map[string]([]string){}
-	priorityPermissions []string              = []string{}
+	// Runs the commands of the command line interface one at a time, since scheduled tasks run them too
+	cliMutex sync.Mutex
 
 	// Application configuration variables
-	useDiscord = flag.Bool("discord", false, "Use Discord mode")                            // Enter or not discord mode
-	configPath = flag.String("config", "config.json", "Path to the configuration file")     // Path to the configuration file
-	envPath    = flag.String("load", "directory.json", "Path to the environment directory") // Path to the environment directory
+	useDiscord    = flag.Bool("discord", false, "Use Discord mode")                            // Enter or not discord mode
+	configPath    = flag.String("config", "config.json", "Path to the configuration file")     // Path to the configuration file
+	envPath       = flag.String("load", "directory.json", "Path to the environment directory") // Path to the environment directory
+	cronStatePath = flag.String("cron", "cron.json", "Path to the scheduler state file")       // Path to the last run times of scheduled tasks
+	sessionsPath  = flag.String("sessions", "sessions.json", "Path to the sessions file")      // Path to the open sessions
+	guildsPath    = flag.String("guilds", "guilds.json", "Path to the guilds file")            // Path to the environments of the guilds
 )
 
 // Event handler for Discord messages
@@ -62,28 +56,14 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 		return
 	}
 
-	// Ignore all messages outside the configured channels
-	isInChannel := false
-	for _, channel := range config["focus_channels"].([]interface{}) {
-		if channel.(string) == message.ChannelID {
-			isInChannel = true
-			// Check to see if the key is part of the currentDir
-			// If not, create it
-			if _, ok := currentDir[message.ChannelID]; !ok {
-				currentDir[message.ChannelID] = &root
-			}
-			break
-		}
-	}
-
-	for i := 0; i < len(openChannels); i++ {
-		if openChannels[i] == message.ChannelID {
-			isInChannel = true
-			break
+	// Direct messages are played in the home guild
+	// Ignore all other messages outside the configured channels
+	direct := message.GuildID == ""
+	if direct {
+		if !openDirectMessage(session, message) {
+			return
 		}
-	}
-
-	if !isInChannel {
+	} else if !isTerminalChannel(message.ChannelID, message.GuildID) {
 		return
 	}
 
@@ -104,6 +84,45 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 		return
 	}
 
+	// Only the owner of a session and the users they invited can use it
+	if !canUseSession(message.ChannelID, message.Author.ID) {
+		return
+	}
+
+	if direct && guildOnlyCommands[commands[0]] {
+		reply(session, message, "Error: This command cannot be used in direct messages.", COLOR_RED)
+		return
+	}
+
+	// Execute the command
+	touchSession(message.ChannelID)
+	executeDiscordCommand(session, message, commands)
+}
+
+// Determine if a channel is used as a terminal
+// Focus channels get their current directory set up on first use
+// @param channelID: string - The channel to check
+// @return bool - True if the channel is a focus or open channel, false otherwise
+func isTerminalChannel(channelID string, guildID string) bool {
+
+	env := guildEnvironment(guildID)
+	if isFocusChannel(env, channelID) {
+		bindChannel(channelID, guildID)
+		// Check to see if the key is part of the currentDir
+		// If not, create it
+		initCurrentDirectory(channelID, &env.root)
+		return true
+	}
+
+	return getSession(channelID) != nil
+}
+
+// Execute a parsed command sent to a Discord channel
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @param commands: []string - The parsed command line
+func executeDiscordCommand(session *discordgo.Session, message *discordgo.MessageCreate, commands []string) {
+
 	// Execute the command
 	switch commands[0] {
 
@@ -111,7 +130,7 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 	case "help":
 
 		if !HasPermission(session, message.Member, message.GuildID, "help") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
@@ -119,112 +138,286 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 
 		if err != nil {
 			fmt.Println("Error: Could not read the help file,", err)
-			echo(session, message.ChannelID, "Error: Could not read the help file.", COLOR_RED)
+			reply(session, message, "Error: Could not read the help file.", COLOR_RED)
 		}
 
-		echo(session, message.ChannelID, string(content), COLOR_WHITE)
+		reply(session, message, string(content), COLOR_WHITE)
 		break
 
 	// Print out a message to the output
 	case "echo":
 
 		if !HasPermission(session, message.Member, message.GuildID, "echo") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
-		echo(session, message.ChannelID, strings.Join(commands[1:], " "), COLOR_WHITE)
+		reply(session, message, strings.Join(commands[1:], " "), COLOR_WHITE)
 		break
 
 	// Get the current directory
 	case "pwd":
 
 		if !HasPermission(session, message.Member, message.GuildID, "pwd") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
-		echo(session, message.ChannelID, getCurrentDirectoryPath(message.ChannelID), COLOR_WHITE)
+		reply(session, message, getCurrentDirectoryPath(message.ChannelID), COLOR_WHITE)
 		break
 
 	// List the contents of the current directory
 	case "ls":
 
 		if !HasPermission(session, message.Member, message.GuildID, "ls") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		target, flags, err := parseLsArgs(commands)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		viewer := &Viewer{session, message.Member, message.GuildID, message.ChannelID, false}
+
+		// Show hidden files and folders
+		if strings.Contains(flags, "a") {
+			if !HasPermission(session, message.Member, message.GuildID, "ls-a") {
+				reply(session, message, "Error: You do not have permission to see hidden files.", COLOR_RED)
+				return
+			}
+			viewer.showAll = true
+		}
+
+		directory, glob, err := listingTarget(target, message.ChannelID)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		// Show the details of each element, or draw the tree
+		if strings.Contains(flags, "l") {
+			reply(session, message, drawLongListing(directory, glob, viewer), COLOR_WHITE)
+		} else {
+			tree := buildTree(directory, DEFAULT_TREE_DEPTH-1, viewer)
+			trimListing(tree, glob, strings.Contains(flags, "1"))
+			replyTree(session, message, tree)
+		}
+
+		break
+
+	// Draw a directory tree
+	case "tree":
+
+		if !HasPermission(session, message.Member, message.GuildID, "tree") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		target, depth, err := parseTreeArgs(commands)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		directory, err := getDirectory(target, true, message.ChannelID)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		replyTree(session, message, buildTree(directory, depth-1, &Viewer{session, message.Member, message.GuildID, message.ChannelID, false}))
+		break
+
+	// Find files and folders by name
+	case "find":
+
+		if !HasPermission(session, message.Member, message.GuildID, "find") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		target, glob, kind, err := parseFindArgs(commands)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		directory, err := getDirectory(target, true, message.ChannelID)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, drawFind(directory, glob, kind, &Viewer{session, message.Member, message.GuildID, message.ChannelID, false}), COLOR_WHITE)
+		break
+
+	// Find the lines of files matching a pattern
+	case "grep":
+
+		if !HasPermission(session, message.Member, message.GuildID, "grep") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		pattern, target, recursive, err := parseGrepArgs(commands)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
 			return
 		}
 
-		tree := drawDirectory(currentDir[message.ChannelID], 4)
-		echo(session, message.ChannelID, tree, COLOR_WHITE)
+		files, several, err := grepFiles(target, recursive, message.ChannelID, &Viewer{session, message.Member, message.GuildID, message.ChannelID, false})
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
 
+		reply(session, message, drawGrep(files, pattern, several), COLOR_WHITE)
 		break
 
 	// Change the current directory
 	case "cd":
 
 		if !HasPermission(session, message.Member, message.GuildID, "cd") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
 		// Check if the directory was specified
 		if len(commands) < 2 {
-			echo(session, message.ChannelID, "Error: No directory was specified. Expecting \"cd <directory>\".", COLOR_RED)
+			reply(session, message, "Error: No directory was specified. Expecting \"cd <directory>\".", COLOR_RED)
 			return
 		}
 
-		nextDir, err := getDirectory(commands[1], true, message.ChannelID)
+		_, err := enterDirectory(commands[1], message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
 
 		if err != nil {
-			echo(session, message.ChannelID, err.Error(), COLOR_RED)
+			reply(session, message, err.Error(), COLOR_RED)
 			return
 		}
 
-		currentDir[message.ChannelID] = nextDir
-		echo(session, message.ChannelID, "Changed directory to "+getCurrentDirectoryPath(message.ChannelID), COLOR_GREEN)
+		reply(session, message, "Changed directory to "+getCurrentDirectoryPath(message.ChannelID), COLOR_GREEN)
 		break
 
 	// Print the contents of a file
 	case "cat":
 
 		if !HasPermission(session, message.Member, message.GuildID, "cat") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
+		// Number the lines or dump the bytes if requested
+		commands, numbered := popFlag(commands, "-n")
+		commands, dump := popFlag(commands, "-x")
+
 		// Get the directory
 		if len(commands) < 2 {
-			echo(session, message.ChannelID, "Error: No file was specified. Expecting \"grab <file>\".", COLOR_RED)
+			reply(session, message, "Error: No file was specified. Expecting \"cat [-n | -x] <file>\".", COLOR_RED)
 			return
 		}
 
-		file, err := getFile(commands[1], true, message.ChannelID)
+		file, err := openFile(commands[1], message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
 		if err != nil {
-			echo(session, message.ChannelID, err.Error(), COLOR_RED)
+			reply(session, message, err.Error(), COLOR_RED)
 			return
 		}
 
-		// Get the file contents
-		if (*file).cache != "" {
+		// Get the file contents from the data or the cache
+		data, err := readFile(file)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
 
-			// Get the file contents from the cache
-			data, err := os.ReadFile((*file).cache)
-			if err != nil {
-				echo(session, message.ChannelID, "Error: Could not open the file \""+(*file).cache+"\".", COLOR_RED)
-				return
+		// Send the file contents to the output, binary files as a hex dump
+		if dump || (isBinary(data) && !isImage(data)) {
+			reply(session, message, drawBinary(file.name, data), COLOR_WHITE)
+		} else if isImage(data) {
+			replyImage(session, message, file.name, data)
+		} else {
+			content := string(data)
+			if numbered {
+				content = selectLines(content, 1, -1, true)
 			}
+			reply(session, message, content, COLOR_WHITE)
+		}
 
-			// Convert the file contents to a string
-			content := string(data)
-			// Send the file contents to the output
-			echo(session, message.ChannelID, content, COLOR_WHITE)
+		break
+
+	// Print the first or last lines of a file
+	case "head", "tail":
+
+		if !HasPermission(session, message.Member, message.GuildID, commands[0]) {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		path, count, numbered, err := parseLineCount(commands)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		file, err := openFile(path, message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		data, err := readFile(file)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		if commands[0] == "head" {
+			reply(session, message, selectLines(string(data), 1, count, numbered), COLOR_WHITE)
+		} else {
+			reply(session, message, selectLines(string(data), -count, count, numbered), COLOR_WHITE)
+		}
+
+		break
+
+	// Print the decoded contents of a file
+	case "decode":
+
+		if !HasPermission(session, message.Member, message.GuildID, "decode") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 3 {
+			reply(session, message, "Error: No encoding or file was specified. Expecting \"decode <base64 | hex> <file>\".", COLOR_RED)
+			return
+		}
+
+		file, err := openFile(commands[2], message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		data, err := readFile(file)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		decoded, err := decodeContents(commands[1], string(data))
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
 
+		if isImage(decoded) {
+			replyImage(session, message, file.name, decoded)
+		} else if isBinary(decoded) {
+			reply(session, message, drawBinary(file.name, decoded), COLOR_WHITE)
 		} else {
-			// Read the file contents from the data
-			content := (*file).data
-			// Send the file contents to the output
-			echo(session, message.ChannelID, content, COLOR_WHITE)
+			reply(session, message, string(decoded), COLOR_WHITE)
 		}
 
 		break
@@ -233,19 +426,19 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 	case "grab":
 
 		if !HasPermission(session, message.Member, message.GuildID, "grab") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
 		// Get the directory
 		if len(commands) < 2 {
-			echo(session, message.ChannelID, "Error: No file was specified. Expecting \"grab <file>\".", COLOR_RED)
+			reply(session, message, "Error: No file was specified. Expecting \"grab <file>\".", COLOR_RED)
 			return
 		}
 
-		file, err := getFile(commands[1], true, message.ChannelID)
+		file, err := openFile(commands[1], message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
 		if err != nil {
-			echo(session, message.ChannelID, "Error: Could not find the file \""+commands[1]+"\".", COLOR_RED)
+			reply(session, message, "Error: Could not find the file \""+commands[1]+"\".", COLOR_RED)
 			return
 		}
 
@@ -254,9 +447,9 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 			// Load from cache folder the file
 			// Check if the file exists
 
-			data, err := os.ReadFile((*file).cache)
+			data, err := readFile(file)
 			if err != nil {
-				echo(session, message.ChannelID, "Error: Could not open the file \""+(*file).cache+"\".", COLOR_RED)
+				reply(session, message, err.Error(), COLOR_RED)
 				return
 			}
 
@@ -264,509 +457,1381 @@ func onDiscordMessage(session *discordgo.Session, message *discordgo.MessageCrea
 			reader := bytes.NewReader(data)
 
 			// Send the file to the user
-			session.ChannelFileSend(message.ChannelID, (*file).name, reader)
+			replyFile(session, message, (*file).name, reader)
 		} else {
 			// Create an io reader from a string
 			reader := strings.NewReader((*file).data)
 
 			// Send the file to the user
-			session.ChannelFileSend(message.ChannelID, (*file).name, reader)
+			replyFile(session, message, (*file).name, reader)
 		}
 
 		break
 
-	// Set a global variable
-	case "set":
+	// Create a link to a file or a directory
+	case "ln":
 
-		if !HasPermission(session, message.Member, message.GuildID, "set") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		if !HasPermission(session, message.Member, message.GuildID, "ln") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
-		// Check if the variable name was specified
+		// Every link is symbolic, the flag is only accepted for habit
+		commands, _ = popFlag(commands, "-s")
+
 		if len(commands) < 3 {
-			echo(session, message.ChannelID, "Error: No variable name was specified. Expecting \"set <variable> <value>\".", COLOR_RED)
+			reply(session, message, "Error: No target or link was specified. Expecting \"ln -s <target> <link>\".", COLOR_RED)
 			return
 		}
 
-		// Check if the variable exists
-		if !systemVariableExists(commands[1]) {
+		link, err := createLink(commands[1], commands[2], message.ChannelID, message.Author.ID)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
 
-			// Create the variable if not found
-			echo(session, message.ChannelID, "Could not already find the variable. Creating a new variable.", COLOR_YELLOW)
-			err := createSystemVariable(commands[1], commands[2])
-			if err != nil {
-				echo(session, message.ChannelID, "Error: Could not create the variable.", COLOR_RED)
-				return
-			}
-			echo(session, message.ChannelID, "Created variable \""+commands[1]+"\" with value \""+commands[2]+"\".\n", COLOR_GREEN)
+		reply(session, message, "Linked \""+link.path+link.name+"\" to \""+link.link+"\".", COLOR_GREEN)
+		break
 
-		} else {
-			// Set the value of the variable
-			err := setSystemVariable(commands[1], commands[2])
+	// Show the details of a file or a folder
+	case "stat":
 
-			if err != nil {
-				echo(session, message.ChannelID, "Error: Could not set the variable value. "+err.Error(), COLOR_RED)
-				return
-			}
+		if !HasPermission(session, message.Member, message.GuildID, "stat") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: No path was specified. Expecting \"stat <path>\".", COLOR_RED)
+			return
+		}
 
-			echo(session, message.ChannelID, "Updated variable \""+commands[1]+"\" with value \""+commands[2]+"\".\n", COLOR_GREEN)
+		details, err := statPath(commands[1], message.ChannelID, &Viewer{session, message.Member, message.GuildID, message.ChannelID, false})
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
 		}
+
+		reply(session, message, details, COLOR_WHITE)
 		break
 
-	// Get a global variable
-	case "get":
+	// List the versions kept for a file
+	case "log":
 
-		if !HasPermission(session, message.Member, message.GuildID, "get") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		if !HasPermission(session, message.Member, message.GuildID, "log") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
-		// Check if the variable name was specified
 		if len(commands) < 2 {
-			echo(session, message.ChannelID, "Error: No variable was specified. Expecting \"get <variable>\".", COLOR_RED)
+			reply(session, message, "Error: No file was specified. Expecting \"log <path>\".", COLOR_RED)
 			return
 		}
 
-		// Return the value of the variable if it exists
-		variable, err := getSystemVariable(commands[1])
+		file, err := historyFile(commands[1], message.ChannelID, nil)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
 
-		// Variable not found
+		reply(session, message, drawLog(file), COLOR_WHITE)
+		break
+
+	// Show the changes made to a file since one of its versions
+	case "diff":
+
+		if !HasPermission(session, message.Member, message.GuildID, "diff") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: No file was specified. Expecting \"diff <path> [version]\".", COLOR_RED)
+			return
+		}
+
+		file, err := historyFile(commands[1], message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
 		if err != nil {
-			echo(session, message.ChannelID, err.Error(), COLOR_RED)
+			reply(session, message, err.Error(), COLOR_RED)
 			return
 		}
 
-		echo(session, message.ChannelID, variable.Value, COLOR_WHITE)
+		rev := ""
+		if len(commands) > 2 {
+			rev = commands[2]
+		}
+
+		changes, err := diffFile(file, rev)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
 
+		reply(session, message, changes, COLOR_WHITE)
 		break
 
-	// Delete a global variable
-	case "delete":
+	// Bring back an earlier version of a file
+	case "revert":
 
-		if !HasPermission(session, message.Member, message.GuildID, "delete") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		if !HasPermission(session, message.Member, message.GuildID, "revert") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 3 {
+			reply(session, message, "Error: No file or version was specified. Expecting \"revert <path> <version>\".", COLOR_RED)
 			return
 		}
 
-		err = deleteSystemVariable(commands[1])
+		file, err := revertFile(commands[1], commands[2], message.ChannelID, message.Author.ID)
 		if err != nil {
-			echo(session, message.ChannelID, err.Error(), COLOR_RED)
+			reply(session, message, err.Error(), COLOR_RED)
 			return
 		}
 
-		echo(session, message.ChannelID, "Deleted variable \""+commands[1]+"\".", COLOR_GREEN)
+		reply(session, message, fmt.Sprintf("Reverted \"%s\" to %s, now at version %d.", file.path+file.name, commands[2], file.meta.version), COLOR_GREEN)
+		break
 
-	case "su":
+	// Pack a file or a directory into a zip archive
+	case "export":
 
-		if !HasPermission(session, message.Member, message.GuildID, "su") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		if !HasPermission(session, message.Member, message.GuildID, "export") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
-		if len(commands) < 3 {
-			echo(session, message.ChannelID, "Error: Incomplete command. Expecting \"su <subscribe | unsubscribe> <role>\".", COLOR_RED)
+		target := "."
+		if len(commands) > 1 {
+			target = commands[1]
+		}
+
+		name, data, err := exportArchive(target, message.ChannelID, &Viewer{session, message.Member, message.GuildID, message.ChannelID, false})
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
 			return
 		}
 
-		switch commands[1] {
-		case "subscribe":
-			err := subscribeToRole(session, message.Member, message.GuildID, message.Author.ID, commands[2])
+		replyFile(session, message, name, bytes.NewReader(data))
+		break
+
+	// Unpack the attached zip archives into a directory
+	case "import":
+
+		if !HasPermission(session, message.Member, message.GuildID, "import") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		mode, args, err := parseImportArgs(commands)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		if len(message.Attachments) < 1 {
+			reply(session, message, "Error: No archive was found. Expecting \"import [-c skip|overwrite|rename] [path]\" with a zip archive attached.", COLOR_RED)
+			return
+		}
+
+		target := "."
+		if len(args) > 0 {
+			target = args[0]
+		}
+
+		// Settings of the archive are only kept if the user could set them
+		allowed := func(permission string) bool {
+			return HasPermission(session, message.Member, message.GuildID, permission)
+		}
+
+		for _, attachment := range message.Attachments {
+			data, err := downloadAttachment(attachment, configInt("import_max_bytes", DEFAULT_IMPORT_MAX_BYTES))
 			if err != nil {
-				echo(session, message.ChannelID, err.Error(), COLOR_RED)
-				return
+				reply(session, message, err.Error(), COLOR_RED)
+				continue
 			}
-			echo(session, message.ChannelID, "Subscribed to role \""+commands[2]+"\".", COLOR_GREEN)
-			break
-		case "unsubscribe":
-			err := unsubscribeFromRole(session, message.Member, message.GuildID, message.Author.ID, commands[2])
+
+			report, err := importArchive(data, attachment.Filename, target, mode, message.ChannelID, message.Author.ID, allowed)
 			if err != nil {
-				echo(session, message.ChannelID, err.Error(), COLOR_RED)
-				return
+				reply(session, message, err.Error(), COLOR_RED)
+				continue
 			}
-			echo(session, message.ChannelID, "Unsubscribed from role \""+commands[2]+"\".", COLOR_GREEN)
-			break
-		default:
-			echo(session, message.ChannelID, "Error: Invalid command. Expecting \"su <subscribe | unsubscribe> <role>\".", COLOR_RED)
+
+			reply(session, message, drawImport(report, attachment.Filename, normalizePath(target, message.ChannelID)), COLOR_GREEN)
 		}
 		break
 
-	case "lock":
+	// Store the attachments of the message as files
+	case "upload":
 
-		if !HasPermission(session, message.Member, message.GuildID, "lock") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		if !HasPermission(session, message.Member, message.GuildID, "upload") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
 			return
 		}
 
-		if len(commands) < 3 {
-			echo(session, message.ChannelID, "Error: Incomplete command. Expecting \"lock <path> <key>\".", COLOR_RED)
+		if len(message.Attachments) < 1 {
+			reply(session, message, "Error: No attachment was found. Expecting \"upload [path]\" with files attached.", COLOR_RED)
 			return
 		}
 
-		file, err := getFile(commands[1], true, message.ChannelID)
-		if err != nil {
-			folder, err := getDirectory(commands[1], true, message.ChannelID)
-			if err != nil {
-				echo(session, message.ChannelID, "Error: Cannot access or find the specified path.", COLOR_RED)
-				return
-			}
-			err = LockFolder(folder, commands[2])
+		target := ""
+		if len(commands) > 1 {
+			target = commands[1]
+		}
+
+		for _, attachment := range message.Attachments {
+			path, err := uploadPath(target, attachment.Filename, message.ChannelID, len(message.Attachments))
 			if err != nil {
-				echo(session, message.ChannelID, err.Error(), COLOR_RED)
+				reply(session, message, err.Error(), COLOR_RED)
 				return
 			}
-		} else {
-			err = LockFile(file, commands[2])
+
+			file, err := uploadAttachment(attachment, path, message.ChannelID, message.Author.ID)
 			if err != nil {
-				echo(session, message.ChannelID, err.Error(), COLOR_RED)
-				return
+				reply(session, message, err.Error(), COLOR_RED)
+				continue
 			}
+			reply(session, message, "Uploaded \""+file.path+file.name+"\".", COLOR_GREEN)
 		}
+		break
 
-		// Redirect to the root
-		currentDir[message.ChannelID] = &root
+	// Set a global variable
+	case "set":
+
+		if !HasPermission(session, message.Member, message.GuildID, "set") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		// Check if the variable name was specified
+		if len(commands) < 3 {
+			reply(session, message, "Error: No variable name was specified. Expecting \"set <variable> <value>\".", COLOR_RED)
+			return
+		}
+
+		// Check if the variable exists
+		if !variableExists(commands[1], message.ChannelID) {
+
+			// Create the variable if not found
+			reply(session, message, "Could not already find the variable. Creating a new variable.", COLOR_YELLOW)
+			err := createVariable(commands[1], commands[2], message.ChannelID)
+			if err != nil {
+				reply(session, message, "Error: Could not create the variable.", COLOR_RED)
+				return
+			}
+			reply(session, message, "Created variable \""+commands[1]+"\" with value \""+commands[2]+"\".\n", COLOR_GREEN)
+
+		} else {
+			// Set the value of the variable
+			err := setVariable(commands[1], commands[2], message.ChannelID)
+
+			if err != nil {
+				reply(session, message, "Error: Could not set the variable value. "+err.Error(), COLOR_RED)
+				return
+			}
+
+			reply(session, message, "Updated variable \""+commands[1]+"\" with value \""+commands[2]+"\".\n", COLOR_GREEN)
+		}
+		break
+
+	// Get a global variable
+	case "get":
+
+		if !HasPermission(session, message.Member, message.GuildID, "get") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		// Check if the variable name was specified
+		if len(commands) < 2 {
+			reply(session, message, "Error: No variable was specified. Expecting \"get <variable>\".", COLOR_RED)
+			return
+		}
+
+		// Return the value of the variable if it exists
+		variable, err := getVariable(commands[1], message.ChannelID)
+
+		// Variable not found
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, variable.Value, COLOR_WHITE)
+
+		break
+
+	// Delete a global variable
+	case "delete":
+
+		if !HasPermission(session, message.Member, message.GuildID, "delete") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		err := deleteVariable(commands[1], message.ChannelID)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, "Deleted variable \""+commands[1]+"\".", COLOR_GREEN)
+
+	case "su":
+
+		if !HasPermission(session, message.Member, message.GuildID, "su") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 3 {
+			reply(session, message, "Error: Incomplete command. Expecting \"su <subscribe | unsubscribe> <role>\".", COLOR_RED)
+			return
+		}
+
+		switch commands[1] {
+		case "subscribe":
+			err := subscribeToRole(session, message.Member, message.GuildID, message.Author.ID, commands[2])
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Subscribed to role \""+commands[2]+"\".", COLOR_GREEN)
+			break
+		case "unsubscribe":
+			err := unsubscribeFromRole(session, message.Member, message.GuildID, message.Author.ID, commands[2])
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Unsubscribed from role \""+commands[2]+"\".", COLOR_GREEN)
+			break
+		default:
+			reply(session, message, "Error: Invalid command. Expecting \"su <subscribe | unsubscribe> <role>\".", COLOR_RED)
+		}
+		break
+
+	case "lock":
+
+		if !HasPermission(session, message.Member, message.GuildID, "lock") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 3 {
+			reply(session, message, "Error: Incomplete command. Expecting \"lock <path> <key>\".", COLOR_RED)
+			return
+		}
+
+		// The element itself may be closed, only the directories leading to it must be open
+		folder, file, err := resolvePath(commands[1], message.ChannelID, true, false)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+		if file == nil {
+			err = LockFolder(folder, commands[2])
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+		} else {
+			err = LockFile(file, commands[2])
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+		}
+
+		// Redirect to the root
+		setCurrentDirectory(message.ChannelID, &channelEnvironment(message.ChannelID).root)
+
+		reply(session, message, "Locked \""+commands[1]+"\" and redirected to root.", COLOR_GREEN)
+		break
+
+	case "unlock":
+
+		if !HasPermission(session, message.Member, message.GuildID, "unlock") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 3 {
+			reply(session, message, "Error: Incomplete command. Expecting \"unlock <path> <key>\".", COLOR_RED)
+			return
+		}
+
+		// The element itself may be closed, only the directories leading to it must be open
+		folder, file, err := resolvePath(commands[1], message.ChannelID, true, false)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+		if file == nil {
+			err = UnlockFolder(folder, commands[2], &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Unlocked \""+commands[1]+"\".", COLOR_GREEN)
+		} else {
+			err = UnlockFile(file, commands[2], &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Unlocked \""+commands[1]+"\".", COLOR_GREEN)
+		}
+
+		break
+
+	case "save":
+
+		if !HasPermission(session, message.Member, message.GuildID, "save") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			fmt.Println("Error: No file name was specified. Expecting \"save <file>\".")
+			return
+		}
+
+		err := saveEnvironment(commands[1], channelEnvironment(message.ChannelID))
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, "Saved environment to file \""+commands[1]+"\".", COLOR_GREEN)
+		break
+
+	// Delete the cache files no environment file uses anymore
+	case "gc":
+
+		if !HasPermission(session, message.Member, message.GuildID, "gc") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		deleted, err := collectBlobs()
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, fmt.Sprintf("Deleted %d unused cache file(s).", deleted), COLOR_GREEN)
+		break
+
+	case "load":
+
+		if !HasPermission(session, message.Member, message.GuildID, "load") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: No file name was specified. Expecting \"load <file>\".", COLOR_RED)
+			return
+		}
+
+		err := loadEnvironment(commands[1], channelEnvironment(message.ChannelID))
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, "Loaded environment from file \""+commands[1]+"\".", COLOR_GREEN)
+		break
+
+	case "new":
+
+		if !HasPermission(session, message.Member, message.GuildID, "new") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		// Choose the kind of session to open
+		kind := SESSION_CHANNEL
+		if defaultKind, ok := config["session_type"].(string); ok {
+			kind = defaultKind
+		}
+		if len(commands) > 1 {
+			kind = commands[1]
+		}
+		if kind != SESSION_CHANNEL && kind != SESSION_PUBLIC_THREAD && kind != SESSION_PRIVATE_THREAD {
+			reply(session, message, "Error: Unknown session type \""+kind+"\". Expecting \"new [channel | thread | private]\".", COLOR_RED)
+			return
+		}
+
+		// Check amount of sessions open by the user
+		env := channelEnvironment(message.ChannelID)
+		if countSessions(message.Author.ID, "", env) >= env.sessionsPerUser {
+			reply(session, message, "Error: You have too many sessions open. Please close some before creating a new one.", COLOR_RED)
+			return
+		}
+
+		parent := sessionParent(message.ChannelID)
+
+		// Open the session in a thread of the focus channel
+		if kind != SESSION_CHANNEL {
+			thread, err := openThreadSession(session, parent, message.Author.ID, kind)
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Created new thread \""+thread.Name+"\".", COLOR_GREEN)
+			echo(session, thread.ID, "New session created. Type \"close\" to end the session.", COLOR_WHITE)
+			break
+		}
+
+		// Check amount of channels active
+		if countSessions("", SESSION_CHANNEL, env) >= env.maxChannels {
+			reply(session, message, "Error: Too many channels open. Please close some before creating a new one.", COLOR_RED)
+			return
+		}
+
+		// Create a new channel on Discord
+		channelName := NewChannelName(5)
+		channel, err := session.GuildChannelCreate(message.GuildID, channelName, discordgo.ChannelTypeGuildText)
+		if err != nil {
+			reply(session, message, "Error: Failed to create channel \""+channelName+"\".", COLOR_RED)
+			return
+		}
+
+		// Get parent channel
+		parentChannel := ""
+		channels, _ := session.GuildChannels(message.GuildID)
+		for i := 0; i < len(channels); i++ {
+			if channels[i].ID == message.ChannelID {
+				parentChannel = channels[i].ParentID
+				break
+			}
+		}
+
+		// Move the channel
+		_, err = session.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{ParentID: parentChannel})
+		if err != nil {
+			println(err.Error())
+			session.ChannelDelete(channel.ID)
+			reply(session, message, "Error: Failed to edit channel \""+channelName+"\".", COLOR_RED)
+			return
+		}
+
+		// Only let the owner in, or do not open the channel at all
+		err = restrictSessionChannel(session, message.GuildID, channel.ID, message.Author.ID)
+		if err != nil {
+			session.ChannelDelete(channel.ID)
+			reply(session, message, "Error: Failed to restrict access to channel \""+channelName+"\".", COLOR_RED)
+			return
+		}
+
+		now := time.Now()
+		openSession(&Session{channel.ID, SESSION_CHANNEL, parent, message.Author.ID, now, now, map[string](*SystemVariable){}, false, []string{}})
+		reply(session, message, "Created new channel \""+channelName+"\".", COLOR_GREEN)
+		echo(session, channel.ID, "New channel created. Type \"close\" to end the channel.", COLOR_WHITE)
+
+		break
+
+	case "close":
+
+		if !HasPermission(session, message.Member, message.GuildID, "close") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		// Check if the channel can be closed
+		// Check if it is a focus channel
+		if isFocusChannel(channelEnvironment(message.ChannelID), message.ChannelID) {
+			reply(session, message, "Error: Cannot close this channel.", COLOR_RED)
+			return
+		}
+
+		// Threads stay archived, so let their users know
+		if opened := getSession(message.ChannelID); opened != nil && opened.kind != SESSION_CHANNEL {
+			reply(session, message, "Session closed.", COLOR_GREEN)
+		}
+
+		err := closeSession(session, message.ChannelID)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+		break
+
+	// Let a user into the session, or take them out
+	case "invite", "kick":
+
+		if !HasPermission(session, message.Member, message.GuildID, commands[0]) {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: No user was specified. Expecting \""+commands[0]+" <user>\".", COLOR_RED)
+			return
+		}
+
+		opened := getSession(message.ChannelID)
+		if opened == nil || opened.owner != message.Author.ID {
+			reply(session, message, "Error: Only the owner of a session can manage its users.", COLOR_RED)
+			return
+		}
+
+		userID := parseUserMention(commands[1])
+		var err error
+		if commands[0] == "invite" {
+			err = inviteToSession(session, message.ChannelID, userID)
+		} else {
+			err = kickFromSession(session, message.ChannelID, userID)
+		}
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		if commands[0] == "invite" {
+			reply(session, message, "Invited <@"+userID+"> to the session.", COLOR_GREEN)
+		} else {
+			reply(session, message, "Removed <@"+userID+"> from the session.", COLOR_GREEN)
+		}
+		break
+
+	// List the open sessions
+	case "sessions":
+
+		if !HasPermission(session, message.Member, message.GuildID, "sessions") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		reply(session, message, drawSessions(session, message.GuildID), COLOR_WHITE)
+		break
+
+	// Show the counters of the outbound messages
+	case "netstat":
+
+		if !HasPermission(session, message.Member, message.GuildID, "netstat") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		reply(session, message, drawOutboundMetrics(), COLOR_WHITE)
+		break
+
+	// Manage the environment of the guild
+	case "guild":
+
+		if !HasPermission(session, message.Member, message.GuildID, "guild") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 || commands[1] == "info" {
+			reply(session, message, drawEnvironment(guildEnvironment(message.GuildID)), COLOR_WHITE)
+			break
+		}
+
+		if commands[1] != "provision" || len(commands) < 3 {
+			reply(session, message, "Error: Invalid command. Expecting \"guild <info | provision <template>>\".", COLOR_RED)
+			return
+		}
+
+		// Give the guild its own copy of the template, played from this channel
+		focusChannel := sessionParent(message.ChannelID)
+		env, err := provisionGuild(message.GuildID, commands[2], focusChannel)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+		bindChannel(focusChannel, message.GuildID)
+		setCurrentDirectory(focusChannel, &env.root)
+
+		reply(session, message, "Provisioned this guild from \""+commands[2]+"\".", COLOR_GREEN)
+		break
+
+	case "run":
+
+		if !HasPermission(session, message.Member, message.GuildID, "run") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		// Remove the background marker from the arguments
+		background := commands[len(commands)-1] == "&"
+		if background {
+			commands = commands[:len(commands)-1]
+		}
+
+		var body []byte
+		programName := ""
+
+		if len(commands) > 1 {
+
+			// Run a program stored in the environment
+			file, err := openFile(commands[1], message.ChannelID, &HookContext{session, message.Member, message.GuildID, message.Author.ID, message.ChannelID})
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+
+			body, err = readFile(file)
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			programName = commands[1]
+
+		} else {
+
+			// Get the first attachment
+			if len(message.Attachments) < 1 {
+				reply(session, message, "Error: No Kode attachment was found.", COLOR_RED)
+				return
+			}
+
+			attachment := message.Attachments[0]
+
+			// Download the attachment
+			resp, err := http.Get(attachment.URL)
+			if err != nil {
+				reply(session, message, "Error: Failed to download attachment.", COLOR_RED)
+				return
+			}
+
+			defer resp.Body.Close()
+
+			body, err = ioutil.ReadAll(resp.Body)
+			if err != nil {
+				reply(session, message, "Error: Failed to read attachment.", COLOR_RED)
+				return
+			}
+			programName = attachment.Filename
+		}
+
+		// Let the program access the environment as the current user
+		host := &KodeHost{session, message.Member, message.GuildID, message.ChannelID}
+
+		// Run the code in the background if requested
+		if background {
+			job, err := startJob(session, message.ChannelID, "run "+programName, body, host)
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+
+			reply(session, message, fmt.Sprintf("[%d] Started \"%s\" in the background.", job.id, programName), COLOR_GREEN)
+			return
+		}
+
+		// Run the code
+		_, result, err := startKode(body, func(line string) {
+			reply(session, message, line, COLOR_WHITE)
+		}, host)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		if kodeCrashed(<-result) {
+			reply(session, message, "Error: Program crashed.", COLOR_RED)
+		}
+
+		reply(session, message, "Done.", COLOR_GREEN)
+
+		break
+
+	// List the background jobs of the channel
+	case "jobs":
+
+		if !HasPermission(session, message.Member, message.GuildID, "jobs") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		reply(session, message, drawJobs(message.ChannelID), COLOR_WHITE)
+
+		// Finished jobs are only reported once
+		pruneJobs(message.ChannelID)
+		break
+
+	// Wait for a background job to end
+	case "fg":
+
+		if !HasPermission(session, message.Member, message.GuildID, "fg") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: No job was specified. Expecting \"fg <job>\".", COLOR_RED)
+			return
+		}
+
+		job, err := getJob(message.ChannelID, commands[1])
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		reply(session, message, fmt.Sprintf("[%d] %s", job.id, job.command), COLOR_WHITE)
+		foregroundJob(job, func(line string) {
+			reply(session, message, line, COLOR_WHITE)
+		})
+		pruneJobs(message.ChannelID)
+		break
+
+	// Stop a background job
+	case "kill":
+
+		if !HasPermission(session, message.Member, message.GuildID, "kill") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: No job was specified. Expecting \"kill <job>\".", COLOR_RED)
+			return
+		}
+
+		job, err := getJob(message.ChannelID, commands[1])
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+
+		err = killJob(job)
+		if err != nil {
+			reply(session, message, err.Error(), COLOR_RED)
+			return
+		}
+		break
+
+	// Wait for one or all background jobs to end
+	case "wait":
+
+		if !HasPermission(session, message.Member, message.GuildID, "wait") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		waitFor := []*Job{}
+		if len(commands) > 1 {
+			job, err := getJob(message.ChannelID, commands[1])
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			waitFor = append(waitFor, job)
+		} else {
+			jobsMutex.Lock()
+			waitFor = append(waitFor, jobs[message.ChannelID]...)
+			jobsMutex.Unlock()
+		}
+
+		for _, job := range waitFor {
+			<-job.done
+		}
+
+		pruneJobs(message.ChannelID)
+		reply(session, message, "Done.", COLOR_GREEN)
+		break
+
+	// Manage scheduled tasks
+	case "cron":
+
+		if !HasPermission(session, message.Member, message.GuildID, "cron") {
+			reply(session, message, "Error: You do not have permission to use this command", COLOR_RED)
+			return
+		}
+
+		if len(commands) < 2 {
+			reply(session, message, "Error: Incomplete command. Expecting \"cron <list | add | rm>\".", COLOR_RED)
+			return
+		}
+
+		switch commands[1] {
+		case "list":
+			reply(session, message, drawCronTasks(channelEnvironment(message.ChannelID)), COLOR_WHITE)
+			break
+		case "add":
+			if len(commands) < 5 {
+				reply(session, message, "Error: Incomplete command. Expecting \"cron add <name> <schedule> <command> [channel]\".", COLOR_RED)
+				return
+			}
+
+			// Run in the current channel unless specified otherwise
+			channel := message.ChannelID
+			if len(commands) > 5 {
+				channel = commands[5]
+			}
+
+			// Tasks may only target the channels of the author's server
+			if channel != message.ChannelID && channel != CRON_ALL_CHANNELS {
+				target, err := session.State.Channel(channel)
+				if err != nil {
+					target, err = session.Channel(channel)
+				}
+				if err != nil || target.GuildID != message.GuildID {
+					reply(session, message, "Error: The channel \""+channel+"\" is not part of this server.", COLOR_RED)
+					return
+				}
+			}
+
+			err := addCronTask(commands[2], commands[3], commands[4], channel, message.Author.ID, false, channelEnvironment(message.ChannelID))
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Scheduled task \""+commands[2]+"\".", COLOR_GREEN)
+			break
+		case "rm":
+			if len(commands) < 3 {
+				reply(session, message, "Error: Incomplete command. Expecting \"cron rm <name>\".", COLOR_RED)
+				return
+			}
+
+			err := removeCronTask(commands[2], channelEnvironment(message.ChannelID))
+			if err != nil {
+				reply(session, message, err.Error(), COLOR_RED)
+				return
+			}
+			reply(session, message, "Removed task \""+commands[2]+"\".", COLOR_GREEN)
+			break
+		default:
+			reply(session, message, "Error: Invalid command. Expecting \"cron <list | add | rm>\".", COLOR_RED)
+		}
+		break
+
+	// Unkown command
+	default:
+		reply(session, message, "Error: Unknown command. Use \"help\" for more information.", COLOR_RED)
+		break
+	}
+
+}
+
+// Event handler for command line interface
+func onCliMessage(message string) {
+
+	cliMutex.Lock()
+	defer cliMutex.Unlock()
+
+	// Parse input command line
+	commands, err := parseCommandLine(message)
+
+	if err != nil {
+		cliEcho("Error: Invalid input format", COLOR_RED)
+		return
+	}
+
+	// Execute the command
+	switch commands[0] {
+
+	// Print out all available commands
+	case "help":
+		content, err := os.ReadFile("help.txt")
+
+		if err != nil {
+			cliEcho("Error: Could not read the help file.", COLOR_RED)
+		}
+
+		cliPrint(string(content))
+		break
+
+	// Print out a message to the output
+	case "echo":
+		fmt.Println(strings.Join(commands[1:], " "))
+		break
 
-		echo(session, message.ChannelID, "Locked \""+commands[1]+"\" and redirected to root.", COLOR_GREEN)
+	// Get the current directory
+	case "pwd":
+		fmt.Println(getCurrentDirectoryPath("default"))
 		break
 
-	case "unlock":
+	// List the contents of the current directory
+	case "ls":
 
-		if !HasPermission(session, message.Member, message.GuildID, "unlock") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		target, flags, err := parseLsArgs(commands)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		if len(commands) < 3 {
-			echo(session, message.ChannelID, "Error: Incomplete command. Expecting \"unlock <path> <key>\".", COLOR_RED)
+		// Show hidden files and folders
+		viewer := &Viewer{nil, nil, "", "default", strings.Contains(flags, "a")}
+
+		directory, glob, err := listingTarget(target, "default")
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		file, err := getFile(commands[1], true, message.ChannelID)
-		if err != nil && file == nil {
-			folder, err := getDirectory(commands[1], true, message.ChannelID)
-			if err != nil && folder == nil {
-				echo(session, message.ChannelID, "Error: Cannot access or find the specified path.", COLOR_RED)
-				return
-			}
-			err = UnlockFolder(folder, commands[2])
-			if err != nil {
-				echo(session, message.ChannelID, err.Error(), COLOR_RED)
-				return
-			}
+		// Show the details of each element, or draw the tree
+		if strings.Contains(flags, "l") {
+			cliPrint(drawLongListing(directory, glob, viewer))
 		} else {
-			err = UnlockFile(file, commands[2])
-			if err != nil {
-				echo(session, message.ChannelID, err.Error(), COLOR_RED)
-				return
-			}
+			tree := buildTree(directory, DEFAULT_TREE_DEPTH-1, viewer)
+			trimListing(tree, glob, strings.Contains(flags, "1"))
+			cliPrint(cliRenderer.renderTree(tree).text)
 		}
 
-		echo(session, message.ChannelID, "Unlocked \""+commands[1]+"\".", COLOR_GREEN)
 		break
 
-	case "save":
+	// Draw a directory tree
+	case "tree":
 
-		if !HasPermission(session, message.Member, message.GuildID, "save") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		target, depth, err := parseTreeArgs(commands)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		if len(commands) < 2 {
-			fmt.Println("Error: No file name was specified. Expecting \"save <file>\".")
+		directory, err := getDirectory(target, true, "default")
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
+
+		cliPrint(cliRenderer.renderTree(buildTree(directory, depth-1, &Viewer{nil, nil, "", "default", false})).text)
+		break
+
+	// Find files and folders by name
+	case "find":
+
+		target, glob, kind, err := parseFindArgs(commands)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		err := saveEnvironment(commands[1])
+		directory, err := getDirectory(target, true, "default")
 		if err != nil {
-			echo(session, message.ChannelID, err.Error(), COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		echo(session, message.ChannelID, "Saved environment to file \""+commands[1]+"\".", COLOR_GREEN)
+		cliPrint(drawFind(directory, glob, kind, &Viewer{nil, nil, "", "default", false}))
 		break
 
-	case "load":
+	// Find the lines of files matching a pattern
+	case "grep":
 
-		if !HasPermission(session, message.Member, message.GuildID, "load") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		pattern, target, recursive, err := parseGrepArgs(commands)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
+
+		files, several, err := grepFiles(target, recursive, "default", &Viewer{nil, nil, "", "default", false})
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
+		cliPrint(drawGrep(files, pattern, several))
+		break
+
+	// Change the current directory
+	case "cd":
+
+		// Check if the directory was specified
 		if len(commands) < 2 {
-			echo(session, message.ChannelID, "Error: No file name was specified. Expecting \"load <file>\".", COLOR_RED)
+			cliEcho("Error: No directory was specified. Expecting \"cd <directory>\".", COLOR_RED)
+			return
 		}
 
-		err := loadEnvironment(commands[1])
+		_, err := enterDirectory(commands[1], "default", &HookContext{nil, nil, "", "", "default"})
+
 		if err != nil {
-			echo(session, message.ChannelID, err.Error(), COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		echo(session, message.ChannelID, "Loaded environment from file \""+commands[1]+"\".", COLOR_GREEN)
+		fmt.Println("Changed directory to " + getCurrentDirectoryPath("default"))
 		break
 
-	case "new":
+	// Print the contents of a file
+	case "cat":
 
-		if !HasPermission(session, message.Member, message.GuildID, "new") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		// Number the lines or dump the bytes if requested
+		commands, numbered := popFlag(commands, "-n")
+		commands, dump := popFlag(commands, "-x")
+
+		if len(commands) < 2 {
+			cliEcho("Error: No file was specified. Expecting \"cat [-n | -x] <file>\".", COLOR_RED)
+			return
 		}
 
-		// Check amount of channels active
-		if len(openChannels) >= MAX_CHANNELS {
-			echo(session, message.ChannelID, "Error: Too many channels open. Please close some before creating a new one.", COLOR_RED)
+		file, err := openFile(commands[1], "default", &HookContext{nil, nil, "", "", "default"})
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		// Create a new channel on Discord
-		channelName := NewChannelName(5)
-		channel, err := session.GuildChannelCreate(message.GuildID, channelName, discordgo.ChannelTypeGuildText)
+		data, err := readFile(file)
 		if err != nil {
-			echo(session, message.ChannelID, "Error: Failed to create channel \""+channelName+"\".", COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		// Get parent channel
-		parentChannel := ""
-		channels, _ := session.GuildChannels(message.GuildID)
-		for i := 0; i < len(channels); i++ {
-			if channels[i].ID == message.ChannelID {
-				parentChannel = channels[i].ParentID
-				break
+		// Images cannot be shown in a terminal, so they are dumped like any binary file
+		if dump || isBinary(data) {
+			cliPrint(drawBinary(file.name, data))
+		} else {
+			content := string(data)
+			if numbered {
+				content = selectLines(content, 1, -1, true)
 			}
+			cliPrint(content)
 		}
 
-		// Move the channel
-		_, err = session.ChannelEditComplex(channel.ID, &discordgo.ChannelEdit{ParentID: parentChannel})
+		break
+
+	// Print the first or last lines of a file
+	case "head", "tail":
+
+		path, count, numbered, err := parseLineCount(commands)
 		if err != nil {
-			println(err.Error())
-			echo(session, message.ChannelID, "Error: Failed to edit channel \""+channelName+"\".", COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		currentDir[channel.ID] = &root
-		openChannels = append(openChannels, channel.ID)
-		echo(session, message.ChannelID, "Created new channel \""+channelName+"\".", COLOR_GREEN)
-		echo(session, channel.ID, "New channel created. Type \"close\" to end the channel.", COLOR_WHITE)
+		file, err := openFile(path, "default", &HookContext{nil, nil, "", "", "default"})
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
+
+		data, err := readFile(file)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
 
+		if commands[0] == "head" {
+			fmt.Print(selectLines(string(data), 1, count, numbered))
+		} else {
+			fmt.Print(selectLines(string(data), -count, count, numbered))
+		}
 		break
 
-	case "close":
+	// Print the decoded contents of a file
+	case "decode":
 
-		if !HasPermission(session, message.Member, message.GuildID, "close") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+		if len(commands) < 3 {
+			cliEcho("Error: No encoding or file was specified. Expecting \"decode <base64 | hex> <file>\".", COLOR_RED)
 			return
 		}
 
-		// Check if the channel can be closed
-		// Check if it is a focus channel
-		canClose := true
-		for _, channel := range config["focus_channels"].([]interface{}) {
-			if channel.(string) == message.ChannelID {
-				canClose = false
-			}
-		}
-		if !canClose {
-			echo(session, message.ChannelID, "Error: Cannot close this channel.", COLOR_RED)
+		file, err := openFile(commands[2], "default", &HookContext{nil, nil, "", "", "default"})
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		// Remove channel from currentDir
-		delete(currentDir, message.ChannelID)
-
-		// Remove channel from openChannels
-		for i := 0; i < len(openChannels); i++ {
-			if openChannels[i] == message.ChannelID {
-				openChannels = append(openChannels[:i], openChannels[i+1:]...)
-				break
-			}
+		data, err := readFile(file)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		// Delete channel
-		_, err := session.ChannelDelete(message.ChannelID)
+		decoded, err := decodeContents(commands[1], string(data))
 		if err != nil {
-			echo(session, message.ChannelID, "Error: Failed to delete channel.", COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
+
+		if isBinary(decoded) {
+			cliPrint(drawBinary(file.name, decoded))
+		} else {
+			cliPrint(string(decoded))
+		}
+
 		break
 
-	case "run":
+	// Download the contents of a file (for discord only)
+	case "grab":
+		break
 
-		if !HasPermission(session, message.Member, message.GuildID, "run") {
-			echo(session, message.ChannelID, "Error: You do not have permission to use this command", COLOR_RED)
+	// Create a link to a file or a directory
+	case "ln":
+
+		// Every link is symbolic, the flag is only accepted for habit
+		commands, _ = popFlag(commands, "-s")
+
+		if len(commands) < 3 {
+			cliEcho("Error: No target or link was specified. Expecting \"ln -s <target> <link>\".", COLOR_RED)
 			return
 		}
 
-		// Get the first attachment
-		if len(message.Attachments) < 1 {
-			echo(session, message.ChannelID, "Error: No Kode attachment was found.", COLOR_RED)
+		link, err := createLink(commands[1], commands[2], "default", "")
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		attachment := message.Attachments[0]
+		cliEcho("Linked \""+link.path+link.name+"\" to \""+link.link+"\".", COLOR_GREEN)
+		break
 
-		// Download the attachment
-		resp, err := http.Get(attachment.URL)
-		if err != nil {
-			echo(session, message.ChannelID, "Error: Failed to download attachment.", COLOR_RED)
+	// Show the details of a file or a folder
+	case "stat":
+
+		if len(commands) < 2 {
+			cliEcho("Error: No path was specified. Expecting \"stat <path>\".", COLOR_RED)
 			return
 		}
 
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
+		details, err := statPath(commands[1], "default", &Viewer{nil, nil, "", "default", false})
 		if err != nil {
-			echo(session, message.ChannelID, "Error: Failed to read attachment.", COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		body = []byte(string(body) + "\nexit\n")
+		cliPrint(details)
+		break
 
-		// Run the code
-		cmd := exec.Command("kode", "-runStdIn")
-		stdin, err := cmd.StdinPipe()
+	// List the versions kept for a file
+	case "log":
 
-		if err != nil {
-			echo(session, message.ChannelID, "Error: Failed to create stdin pipe.", COLOR_RED)
+		if len(commands) < 2 {
+			cliEcho("Error: No file was specified. Expecting \"log <path>\".", COLOR_RED)
+			return
 		}
 
-		stdout, err := cmd.StdoutPipe()
-
+		file, err := historyFile(commands[1], "default", nil)
 		if err != nil {
-			echo(session, message.ChannelID, "Error: Failed to create stdout pipe.", COLOR_RED)
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		scanner := bufio.NewScanner(stdout)
-		done := make(chan bool)
-
-		go func() {
-			defer stdin.Close()
-
-			if _, err := stdin.Write(body); err != nil {
-				echo(session, message.ChannelID, "Error: Failed to write to stdin.", COLOR_RED)
-			}
+		cliPrint(drawLog(file))
+		break
 
-			for scanner.Scan() {
-				if scanner.Text() == "exit" {
-					done <- true
-				}
-				echo(session, message.ChannelID, scanner.Text(), COLOR_WHITE)
-			}
-			done <- true
-		}()
+	// Show the changes made to a file since one of its versions
+	case "diff":
 
-		cmd.Start()
-		<-done
-		err = cmd.Wait()
+		if len(commands) < 2 {
+			cliEcho("Error: No file was specified. Expecting \"diff <path> [version]\".", COLOR_RED)
+			return
+		}
 
+		file, err := historyFile(commands[1], "default", &HookContext{nil, nil, "", "", "default"})
 		if err != nil {
-			// println("Kode: " + err.Error())
-			if err.Error() != "exit status 1" && err.Error() != "exit status 0xc000013a" {
-				echo(session, message.ChannelID, "Error: Program crashed.", COLOR_RED)
-			}
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		echo(session, message.ChannelID, "Done.", COLOR_GREEN)
+		rev := ""
+		if len(commands) > 2 {
+			rev = commands[2]
+		}
 
-		break
+		changes, err := diffFile(file, rev)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
 
-	// Unkown command
-	default:
-		echo(session, message.ChannelID, "Error: Unknown command. Use \"help\" for more information.", COLOR_RED)
+		cliPrint(changes)
 		break
-	}
-
-}
-
-// Event handler for command line interface
-func onCliMessage(message string) {
-
-	// Parse input command line
-	commands, err := parseCommandLine(message)
-
-	if err != nil {
-		fmt.Println("Error: Invalid input format")
-		return
-	}
 
-	// Execute the command
-	switch commands[0] {
+	// Bring back an earlier version of a file
+	case "revert":
 
-	// Print out all available commands
-	case "help":
-		content, err := os.ReadFile("help.txt")
+		if len(commands) < 3 {
+			cliEcho("Error: No file or version was specified. Expecting \"revert <path> <version>\".", COLOR_RED)
+			return
+		}
 
+		file, err := revertFile(commands[1], commands[2], "default", "")
 		if err != nil {
-			fmt.Println("Error: Could not read the help file.")
+			cliEcho(err.Error(), COLOR_RED)
+			return
 		}
 
-		fmt.Println(string(content))
+		cliEcho(fmt.Sprintf("Reverted \"%s\" to %s, now at version %d.", file.path+file.name, commands[2], file.meta.version), COLOR_GREEN)
 		break
 
-	// Print out a message to the output
-	case "echo":
-		fmt.Println(strings.Join(commands[1:], " "))
-		break
+	// Pack a file or a directory into a zip archive on the host
+	case "export":
 
-	// Get the current directory
-	case "pwd":
-		fmt.Println(getCurrentDirectoryPath("default"))
-		break
+		if len(commands) < 3 {
+			cliEcho("Error: No path or archive was specified. Expecting \"export <path> <archive>\".", COLOR_RED)
+			return
+		}
 
-	// List the contents of the current directory
-	case "ls":
+		_, data, err := exportArchive(commands[1], "default", &Viewer{nil, nil, "", "default", false})
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
 
-		tree := drawDirectory(currentDir["default"], 4)
-		fmt.Println(tree)
+		err = os.WriteFile(commands[2], data, 0644)
+		if err != nil {
+			cliEcho("Error: Could not write the archive \""+commands[2]+"\".", COLOR_RED)
+			return
+		}
 
+		cliEcho(fmt.Sprintf("Exported \"%s\" to \"%s\" (%d bytes).", normalizePath(commands[1], "default"), commands[2], len(data)), COLOR_GREEN)
 		break
 
-	// Change the current directory
-	case "cd":
+	// Unpack a zip archive of the host into a directory
+	case "import":
 
-		// Check if the directory was specified
-		if len(commands) < 2 {
-			fmt.Println("Error: No directory was specified. Expecting \"cd <directory>\".")
+		mode, args, err := parseImportArgs(commands)
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
-		nextDir, err := getDirectory(commands[1], true, "default")
+		if len(args) < 2 {
+			cliEcho("Error: No path or archive was specified. Expecting \"import [-c skip|overwrite|rename] <path> <archive>\".", COLOR_RED)
+			return
+		}
 
+		info, err := os.Stat(args[1])
+		if err != nil || info.Size() > int64(configInt("import_max_bytes", DEFAULT_IMPORT_MAX_BYTES)) {
+			cliEcho("Error: Could not read the archive \""+args[1]+"\", or it is too large.", COLOR_RED)
+			return
+		}
+		data, err := os.ReadFile(args[1])
 		if err != nil {
-			fmt.Println(err.Error())
+			cliEcho("Error: Could not read the archive \""+args[1]+"\".", COLOR_RED)
 			return
 		}
 
-		currentDir["default"] = nextDir
-		fmt.Println("Changed directory to " + getCurrentDirectoryPath("default"))
-		break
-
-	// Print the contents of a file
-	case "cat":
-		break
+		report, err := importArchive(data, info.Name(), args[0], mode, "default", "", func(string) bool { return true })
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
 
-	// Download the contents of a file (for discord only)
-	case "grab":
+		cliEcho(drawImport(report, info.Name(), normalizePath(args[0], "default")), COLOR_GREEN)
 		break
 
 	// Set a global variable
 	case "set":
 		// Check if the variable name was specified
 		if len(commands) < 3 {
-			fmt.Println("Error: No variable name or value was specified. Expecting \"set <variable> <value>\".")
+			cliEcho("Error: No variable name or value was specified. Expecting \"set <variable> <value>\".", COLOR_RED)
 			return
 		}
 
 		// Check if the variable exists
-		if !systemVariableExists(commands[1]) {
+		if !systemVariableExists(commands[1], defaultEnvironment) {
 
 			// Create the variable if not found
 			fmt.Println("Could not already find the variable. Creating a new variable.")
-			err := createSystemVariable(commands[1], commands[2])
+			err := createSystemVariable(commands[1], commands[2], defaultEnvironment)
 			if err != nil {
-				fmt.Println("Error: Could not create the variable.")
+				cliEcho("Error: Could not create the variable.", COLOR_RED)
 				return
 			}
 			fmt.Printf("Created variable \"%s\" with value \"%s\".\n", commands[1], commands[2])
 
 		} else {
 			// Set the value of the variable
-			err := setSystemVariable(commands[1], commands[2])
+			err := setSystemVariable(commands[1], commands[2], defaultEnvironment)
 
 			if err != nil {
 				fmt.Println("Error: Could not set the variable value.", err)
@@ -781,16 +1846,16 @@ func onCliMessage(message string) {
 	case "get":
 		// Check if the variable name was specified
 		if len(commands) < 2 {
-			fmt.Println("Error: No variable was specified. Expecting \"get <variable>\".")
+			cliEcho("Error: No variable was specified. Expecting \"get <variable>\".", COLOR_RED)
 			return
 		}
 
 		// Return the value of the variable if it exists
-		variable, err := getSystemVariable(commands[1])
+		variable, err := getSystemVariable(commands[1], defaultEnvironment)
 
 		// Variable not found
 		if err != nil {
-			fmt.Println(err.Error())
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
@@ -800,9 +1865,9 @@ func onCliMessage(message string) {
 	// Delete a global variable
 	case "delete":
 
-		err = deleteSystemVariable(commands[1])
+		err = deleteSystemVariable(commands[1], defaultEnvironment)
 		if err != nil {
-			fmt.Println(err.Error())
+			cliEcho(err.Error(), COLOR_RED)
 			return
 		}
 
@@ -812,27 +1877,39 @@ func onCliMessage(message string) {
 	case "save":
 
 		if len(commands) < 2 {
-			fmt.Println("Error: No file name was specified. Expecting \"save <file>\".")
+			cliEcho("Error: No file name was specified. Expecting \"save <file>\".", COLOR_RED)
 			return
 		}
 
-		err := saveEnvironment(commands[1])
+		err := saveEnvironment(commands[1], defaultEnvironment)
 		if err != nil {
-			fmt.Println(err.Error())
+			cliEcho(err.Error(), COLOR_RED)
 		}
 
 		fmt.Println("Saved environment to file \"" + commands[1] + "\".")
 		break
 
+	// Delete the cache files no environment file uses anymore
+	case "gc":
+
+		deleted, err := collectBlobs()
+		if err != nil {
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
+
+		cliEcho(fmt.Sprintf("Deleted %d unused cache file(s).", deleted), COLOR_GREEN)
+		break
+
 	case "load":
 
 		if len(commands) < 2 {
-			fmt.Println("Error: No file name was specified. Expecting \"load <file>\".")
+			cliEcho("Error: No file name was specified. Expecting \"load <file>\".", COLOR_RED)
 		}
 
-		err := loadEnvironment(commands[1])
+		err := loadEnvironment(commands[1], defaultEnvironment)
 		if err != nil {
-			fmt.Println(err.Error())
+			cliEcho(err.Error(), COLOR_RED)
 		}
 
 		fmt.Println("Loaded environment from file \"" + commands[1] + "\".")
@@ -841,26 +1918,26 @@ func onCliMessage(message string) {
 	case "lock":
 
 		if len(commands) < 3 {
-			fmt.Println("Error: Incomplete command. Expecting \"lock <path> <key>\".")
+			cliEcho("Error: Incomplete command. Expecting \"lock <path> <key>\".", COLOR_RED)
 			return
 		}
 
-		file, err := getFile(commands[1], true, "default")
+		// The element itself may be closed, only the directories leading to it must be open
+		folder, file, err := resolvePath(commands[1], "default", true, false)
 		if err != nil {
-			folder, err := getDirectory(commands[1], true, "default")
-			if err != nil {
-				fmt.Println("Error: Cannot access or find the specified path.")
-				return
-			}
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
+		if file == nil {
 			err = LockFolder(folder, commands[2])
 			if err != nil {
-				fmt.Println(err.Error())
+				cliEcho(err.Error(), COLOR_RED)
 				return
 			}
 		} else {
 			err = LockFile(file, commands[2])
 			if err != nil {
-				fmt.Println(err.Error())
+				cliEcho(err.Error(), COLOR_RED)
 				return
 			}
 		}
@@ -871,36 +1948,80 @@ func onCliMessage(message string) {
 	case "unlock":
 
 		if len(commands) < 3 {
-			fmt.Println("Error: Incomplete command. Expecting \"unlock <path> <key>\".")
+			cliEcho("Error: Incomplete command. Expecting \"unlock <path> <key>\".", COLOR_RED)
 			return
 		}
 
-		file, err := getFile(commands[1], true, "default")
+		// The element itself may be closed, only the directories leading to it must be open
+		folder, file, err := resolvePath(commands[1], "default", true, false)
 		if err != nil {
-			folder, err := getDirectory(commands[1], true, "default")
+			cliEcho(err.Error(), COLOR_RED)
+			return
+		}
+		if file == nil {
+			err = UnlockFolder(folder, commands[2], &HookContext{nil, nil, "", "", "default"})
 			if err != nil {
-				fmt.Println("Error: Cannot access or find the specified path.")
+				cliEcho(err.Error(), COLOR_RED)
 				return
 			}
-			err = UnlockFolder(folder, commands[2])
+			fmt.Println("Unlocked \"" + commands[1] + "\".")
+		} else {
+			err = UnlockFile(file, commands[2], &HookContext{nil, nil, "", "", "default"})
 			if err != nil {
-				fmt.Println(err.Error())
+				cliEcho(err.Error(), COLOR_RED)
 				return
 			}
-		} else {
-			err = UnlockFile(file, commands[2])
+			fmt.Println("Unlocked \"" + commands[1] + "\".")
+		}
+
+		break
+
+	// Manage scheduled tasks
+	case "cron":
+
+		if len(commands) < 2 {
+			cliEcho("Error: Incomplete command. Expecting \"cron <list | add | rm>\".", COLOR_RED)
+			return
+		}
+
+		switch commands[1] {
+		case "list":
+			fmt.Println(drawCronTasks(defaultEnvironment))
+			break
+		case "add":
+			if len(commands) < 5 {
+				cliEcho("Error: Incomplete command. Expecting \"cron add <name> <schedule> <command>\".", COLOR_RED)
+				return
+			}
+
+			err := addCronTask(commands[2], commands[3], commands[4], "default", "", false, defaultEnvironment)
 			if err != nil {
-				fmt.Println(err.Error())
+				cliEcho(err.Error(), COLOR_RED)
+				return
+			}
+			fmt.Printf("Scheduled task \"%s\".\n", commands[2])
+			break
+		case "rm":
+			if len(commands) < 3 {
+				cliEcho("Error: Incomplete command. Expecting \"cron rm <name>\".", COLOR_RED)
 				return
 			}
-		}
 
-		fmt.Println("Unlocked \"" + commands[1] + "\".")
+			err := removeCronTask(commands[2], defaultEnvironment)
+			if err != nil {
+				cliEcho(err.Error(), COLOR_RED)
+				return
+			}
+			fmt.Printf("Removed task \"%s\".\n", commands[2])
+			break
+		default:
+			cliEcho("Error: Invalid command. Expecting \"cron <list | add | rm>\".", COLOR_RED)
+		}
 		break
 
 	// Unkown command
 	default:
-		fmt.Println("Error: Unknown command. Use \"help\" for more information.")
+		cliEcho("Error: Unknown command. Use \"help\" for more information.", COLOR_RED)
 		break
 	}
 }
@@ -921,9 +2042,18 @@ func main() {
 		return
 	}
 
+	// Settings of the default environment
+	if focusChannels, ok := config["focus_channels"].([]interface{}); ok {
+		for _, channel := range focusChannels {
+			defaultEnvironment.focusChannels = append(defaultEnvironment.focusChannels, channel.(string))
+		}
+	}
+	defaultEnvironment.maxChannels = configInt("max_channels", MAX_CHANNELS)
+	defaultEnvironment.sessionsPerUser = configInt("max_sessions_per_user", DEFAULT_SESSIONS_PER_USER)
+
 	// Load the root directory and environment
 	fmt.Println("Loaded configurations.")
-	err = loadEnvironment(*envPath)
+	err = loadEnvironment(*envPath, defaultEnvironment)
 	if err != nil {
 		fmt.Println("Error: Could not load the environment.", err)
 		return
@@ -942,8 +2072,13 @@ func main() {
 			return
 		}
 
-		// Listen for messages on Discord
+		// Listen for messages and slash commands on Discord
 		client.AddHandler(onDiscordMessage)
+		client.AddHandler(onDiscordInteraction)
+		client.AddHandler(onGuildCreate)
+		client.AddHandler(onThreadUpdate)
+		client.AddHandler(onThreadDelete)
+		client.AddHandler(onChannelDelete)
 
 		// Give proper permissions to the bot
 		client.Identify.Intents = discordgo.IntentsAll
@@ -957,12 +2092,20 @@ func main() {
 
 		fmt.Printf("KuriOS v%s is now running. Press CTRL-C to exit.\n", VERSION)
 
+		// Reattach the sessions opened before the restart
+		loadSessions(client)
+		startSessionReaper(client)
+
+		// Start running the scheduled tasks
+		startScheduler(client)
+
 		// Wait for the program to finish
 		sc := make(chan os.Signal, 1)
 		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
 		<-sc
 
 		// End the bot
+		saveSessions()
 		client.Close()
 
 	} else {
@@ -974,6 +2117,9 @@ func main() {
 		reader := bufio.NewReader(os.Stdin)
 		fmt.Printf("KuriOS v%s is now running. Type \"help\" for more information.\n", VERSION)
 
+		// Start running the scheduled tasks
+		startScheduler(nil)
+
 		// Exit the program when the user presses CTRL-C
 		sc := make(chan os.Signal, 1)
 		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
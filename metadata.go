@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// Format of the times kept in the metadata
+const METADATA_TIME_FORMAT = "2006-01-02 15:04:05"
+
+// Structure of the details kept about a file or a folder
+type Metadata struct {
+	created     time.Time
+	modified    time.Time
+	author      string
+	description string
+	tags        []string
+	version     int
+}
+
+// Create the metadata of a new element
+// @param author: string - The user ID of the author, or empty if unknown
+// @return Metadata - The metadata, at version 1
+func newMetadata(author string) Metadata {
+	now := time.Now()
+	return Metadata{now, now, author, "", []string{}, 1}
+}
+
+// Record a change of the contents of an element
+// The author is only set when the element had none
+// @param meta: *Metadata - The metadata of the element
+// @param author: string - The user ID of the author of the change, or empty if unknown
+func (meta *Metadata) touch(author string) {
+	meta.modified = time.Now()
+	if meta.author == "" {
+		meta.author = author
+	}
+	meta.version++
+}
+
+// Load the metadata of an element
+// Elements saved before metadata existed get empty metadata, and "owner" is read as the author
+// @param element: gjson.Result - The element
+// @return Metadata - The metadata
+func loadMetadata(element gjson.Result) Metadata {
+
+	meta := Metadata{time.Time{}, time.Time{}, element.Get("author").String(), element.Get("description").String(), []string{}, int(element.Get("version").Int())}
+	if meta.author == "" {
+		meta.author = element.Get("owner").String()
+	}
+
+	for key, value := range map[string]*time.Time{"created": &meta.created, "modified": &meta.modified} {
+		if !element.Get(key).Exists() {
+			continue
+		}
+		parsed, err := time.ParseInLocation(METADATA_TIME_FORMAT, element.Get(key).String(), time.Local)
+		if err != nil {
+			fmt.Printf("Exception encountered while loading time \"%s\". Invalid time format. Expected YYYY-MM-DD HH:MM:SS.\n", element.Get(key).String())
+			continue
+		}
+		*value = parsed
+	}
+
+	for _, tag := range element.Get("tags").Array() {
+		meta.tags = append(meta.tags, tag.String())
+	}
+
+	return meta
+}
+
+// Save the metadata of an element
+// @param meta: Metadata - The metadata
+// @return string - The keys of the metadata, each followed by a comma, or empty if there are none
+func _saveMetadata(meta Metadata) string {
+
+	output := ""
+	if !meta.created.IsZero() {
+		output += "\"created\": \"" + meta.created.Format(METADATA_TIME_FORMAT) + "\","
+	}
+	if !meta.modified.IsZero() {
+		output += "\"modified\": \"" + meta.modified.Format(METADATA_TIME_FORMAT) + "\","
+	}
+	if meta.author != "" {
+		output += "\"author\": " + jsonString(meta.author) + ","
+	}
+	if meta.description != "" {
+		output += "\"description\": " + jsonString(meta.description) + ","
+	}
+	if len(meta.tags) > 0 {
+		tags := []string{}
+		for _, tag := range meta.tags {
+			tags = append(tags, jsonString(tag))
+		}
+		output += "\"tags\": [" + strings.Join(tags, ", ") + "],"
+	}
+	if meta.version > 0 {
+		output += "\"version\": " + fmt.Sprint(meta.version) + ","
+	}
+
+	return output
+}
+
+// Draw a time of the metadata
+// @param value: time.Time - The time
+// @return string - The time, or "-" if unknown
+func _drawTime(value time.Time) string {
+	if value.IsZero() {
+		return "-"
+	}
+	return value.Format(METADATA_TIME_FORMAT)
+}
+
+// Draw the details of a file or a folder
+// Links are described themselves, not their target
+// @param folder: *Folder - The folder, if any
+// @param file: *File - The file, if any
+// @param viewer: *Viewer - Who is asking, only the entries they can see are counted
+// @return string - One line per detail
+func drawStat(folder *Folder, file *File, viewer *Viewer) string {
+
+	var output bytes.Buffer
+	writer := tabwriter.NewWriter(&output, 0, 0, 2, ' ', 0)
+
+	var meta Metadata
+	var availableBetween []string
+	var locked bool
+
+	if folder != nil {
+		meta, availableBetween, locked = folder.meta, folder.availableBetween, folder.locked
+		fmt.Fprintf(writer, "Path:\t%s/\n", strings.TrimSuffix(getFolderPath(folder), "/"))
+		if folder.mounted != nil {
+			fmt.Fprintf(writer, "Type:\tmount of %s\n", folder.mounted.mount.source)
+		} else {
+			fmt.Fprintf(writer, "Type:\tdirectory\n")
+		}
+		fmt.Fprintf(writer, "Entries:\t%d\n", len(visibleFolders(folder, viewer))+len(visibleFiles(folder, viewer)))
+	} else {
+		meta, availableBetween, locked = file.meta, file.availableBetween, file.locked
+		fmt.Fprintf(writer, "Path:\t%s\n", file.path+file.name)
+		switch {
+		case file.link != "":
+			fmt.Fprintf(writer, "Type:\tlink to %s\n", file.link)
+		case file.mounted != nil:
+			fmt.Fprintf(writer, "Type:\tmounted file\n")
+		default:
+			fmt.Fprintf(writer, "Type:\tfile\n")
+		}
+		if file.link == "" {
+			size := "unknown"
+			if known := fileSize(file); known >= 0 {
+				size = fmt.Sprintf("%d bytes", known)
+			}
+			fmt.Fprintf(writer, "Size:\t%s\n", size)
+		}
+	}
+
+	fmt.Fprintf(writer, "State:\t%s\n", nodeState(availableBetween, locked))
+	fmt.Fprintf(writer, "Window:\t%s\n", _drawWindow(availableBetween))
+	fmt.Fprintf(writer, "Author:\t%s\n", _drawAuthor(meta.author))
+	fmt.Fprintf(writer, "Created:\t%s\n", _drawTime(meta.created))
+	fmt.Fprintf(writer, "Modified:\t%s\n", _drawTime(meta.modified))
+	fmt.Fprintf(writer, "Version:\t%d\n", meta.version)
+	if meta.description != "" {
+		fmt.Fprintf(writer, "Description:\t%s\n", meta.description)
+	}
+	if len(meta.tags) > 0 {
+		fmt.Fprintf(writer, "Tags:\t%s\n", strings.Join(meta.tags, ", "))
+	}
+
+	writer.Flush()
+	return output.String()
+}
+
+// Describe the element at a path
+// Hidden elements the viewer cannot see are reported as missing
+// @param path: string - The path, absolute or relative
+// @param channel: string - The channel used to resolve relative paths
+// @param viewer: *Viewer - Who is asking
+// @return string - The details of the element
+// @return error - The error if the element cannot be found
+func statPath(path string, channel string, viewer *Viewer) (string, error) {
+
+	folder, file, err := lookupPath(path, channel)
+	if err != nil {
+		return "", err
+	}
+
+	if folder != nil && !isVisible(folder.name, folder.hidden, folder.revealWhen, viewer) || file != nil && !isVisible(file.name, file.hidden, file.revealWhen, viewer) {
+		return "", &PathError{errPathNotFound, normalizePath(path, channel)}
+	}
+
+	return drawStat(folder, file, viewer), nil
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Kinds of mounts
+	MOUNT_DIRECTORY = "directory"
+	MOUNT_ZIP       = "zip"
+	MOUNT_TAR       = "tar"
+
+	// Default directory holding the mounted directories and archives
+	DEFAULT_MOUNT_DIR = "mounts"
+)
+
+// Structure of a host directory or archive mapped into the directory tree
+type Mount struct {
+	source   string
+	kind     string
+	readOnly bool
+	index    map[string]([]MountEntry)
+	sizes    map[string]int64
+	mutex    sync.Mutex
+}
+
+// Structure of an entry listed by a mount
+type MountEntry struct {
+	name string
+	dir  bool
+}
+
+// Structure linking a folder or a file of the tree to its entry in a mount
+type MountNode struct {
+	mount  *Mount
+	entry  string
+	loaded bool
+}
+
+// Get the directory holding the mounted directories and archives
+// Set by "mount_dir" in the configuration file
+// @return string - The absolute path of the directory
+func mountRoot() string {
+	dir := DEFAULT_MOUNT_DIR
+	if configured, ok := config["mount_dir"].(string); ok && configured != "" {
+		dir = configured
+	}
+	if absolute, err := filepath.Abs(dir); err == nil {
+		return absolute
+	}
+	return dir
+}
+
+// Create a mount from its source
+// Archives are always read-only, directories unless asked otherwise
+// @param source: string - The directory or archive, relative to the mount directory
+// @param readOnly: bool - Whether or not the files can be written
+// @return *Mount - The mount
+// @return error - The error if the source cannot be mounted
+func newMount(source string, readOnly bool) (*Mount, error) {
+
+	hostPath, ok := resolveInside(mountRoot(), source)
+	if !ok {
+		return nil, errors.New("Error: The mount \"" + source + "\" is outside of the mount directory.")
+	}
+
+	info, err := os.Stat(hostPath)
+	if err != nil {
+		return nil, errors.New("Error: Could not find the mount \"" + source + "\".")
+	}
+
+	kind := MOUNT_DIRECTORY
+	lower := strings.ToLower(source)
+	if !info.IsDir() {
+		switch {
+		case strings.HasSuffix(lower, ".zip"):
+			kind = MOUNT_ZIP
+		case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+			kind = MOUNT_TAR
+		default:
+			return nil, errors.New("Error: The mount \"" + source + "\" is not a directory, a zip or a tar archive.")
+		}
+	}
+
+	if kind != MOUNT_DIRECTORY && !readOnly {
+		return nil, errors.New("Error: The archive \"" + source + "\" can only be mounted read-only.")
+	}
+
+	return &Mount{source, kind, readOnly, nil, nil, sync.Mutex{}}, nil
+}
+
+// Get the path of a mount on the host
+// @param mount: *Mount - The mount
+// @return string - The path on the host
+func (mount *Mount) hostPath() string {
+	hostPath, _ := resolveInside(mountRoot(), mount.source)
+	return hostPath
+}
+
+// Get the path of an entry of a mounted directory on the host
+// Symbolic links leaving the mounted directory are refused
+// @param mount: *Mount - The mount
+// @param entry: string - The entry
+// @return string - The path on the host
+// @return error - The error if the entry is outside of the mount
+func (mount *Mount) entryPath(entry string) (string, error) {
+
+	root := mount.hostPath()
+	hostPath, ok := resolveInside(root, entry)
+	if !ok {
+		return "", errors.New("Error: The entry \"" + entry + "\" is outside of the mount.")
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", errors.New("Error: Could not open the mount \"" + mount.source + "\".")
+	}
+	if real, err := filepath.EvalSymlinks(hostPath); err == nil {
+		relative, err := filepath.Rel(realRoot, real)
+		if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return "", errors.New("Error: The entry \"" + entry + "\" is outside of the mount.")
+		}
+	}
+
+	return hostPath, nil
+}
+
+// List the entries of a directory of a mount
+// @param mount: *Mount - The mount
+// @param entry: string - The directory, empty for the root of the mount
+// @return []MountEntry - The entries of the directory
+// @return error - The error if the directory cannot be listed
+func (mount *Mount) list(entry string) ([]MountEntry, error) {
+
+	if mount.kind == MOUNT_DIRECTORY {
+		hostPath, err := mount.entryPath(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		items, err := ioutil.ReadDir(hostPath)
+		if err != nil {
+			return nil, errors.New("Error: Could not list the mount \"" + mount.source + "\".")
+		}
+
+		entries := []MountEntry{}
+		for _, item := range items {
+			entries = append(entries, MountEntry{item.Name(), item.IsDir()})
+		}
+		return entries, nil
+	}
+
+	// Archives are indexed once, their contents are only read when needed
+	mount.mutex.Lock()
+	defer mount.mutex.Unlock()
+	if mount.index == nil {
+		names, sizes, err := mount._archiveNames()
+		if err != nil {
+			return nil, err
+		}
+		mount.index = _indexArchive(names)
+		mount.sizes = sizes
+	}
+
+	return mount.index[entry], nil
+}
+
+// Get the size of a file of a mount
+// @param mount: *Mount - The mount
+// @param entry: string - The file
+// @return int64 - The size of the file in bytes, or -1 if unknown
+func (mount *Mount) size(entry string) int64 {
+
+	if mount.kind == MOUNT_DIRECTORY {
+		hostPath, err := mount.entryPath(entry)
+		if err != nil {
+			return -1
+		}
+		info, err := os.Stat(hostPath)
+		if err != nil {
+			return -1
+		}
+		return info.Size()
+	}
+
+	mount.mutex.Lock()
+	defer mount.mutex.Unlock()
+	if size, ok := mount.sizes[entry]; ok {
+		return size
+	}
+	return -1
+}
+
+// Read the contents of a file of a mount
+// @param mount: *Mount - The mount
+// @param entry: string - The file
+// @return []byte - The contents of the file
+// @return error - The error if the file cannot be read
+func (mount *Mount) read(entry string) ([]byte, error) {
+
+	switch mount.kind {
+	case MOUNT_DIRECTORY:
+		hostPath, err := mount.entryPath(entry)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadFile(hostPath)
+		if err != nil {
+			return nil, errors.New("Error: Could not read the file \"" + entry + "\" of the mount \"" + mount.source + "\".")
+		}
+		return data, nil
+
+	case MOUNT_ZIP:
+		archive, err := zip.OpenReader(mount.hostPath())
+		if err != nil {
+			return nil, errors.New("Error: Could not open the archive \"" + mount.source + "\".")
+		}
+		defer archive.Close()
+
+		for _, file := range archive.File {
+			if _cleanEntry(file.Name) == entry && !file.FileInfo().IsDir() {
+				reader, err := file.Open()
+				if err != nil {
+					break
+				}
+				defer reader.Close()
+				return ioutil.ReadAll(reader)
+			}
+		}
+
+	case MOUNT_TAR:
+		var data []byte
+		found := false
+		err := mount._walkTar(func(header *tar.Header, reader io.Reader) bool {
+			if _cleanEntry(header.Name) == entry && header.Typeflag != tar.TypeDir {
+				data, _ = ioutil.ReadAll(reader)
+				found = true
+				return false
+			}
+			return true
+		})
+		if err == nil && found {
+			return data, nil
+		}
+	}
+
+	return nil, errors.New("Error: Could not read the file \"" + entry + "\" of the archive \"" + mount.source + "\".")
+}
+
+// Write the contents of a file of a mounted directory
+// @param mount: *Mount - The mount
+// @param entry: string - The file
+// @param data: string - The new contents of the file
+// @return error - The error if the mount is read-only or the file cannot be written
+func (mount *Mount) write(entry string, data string) error {
+
+	if mount.readOnly {
+		return errors.New("Error: The mount \"" + mount.source + "\" is read-only.")
+	}
+
+	hostPath, err := mount.entryPath(entry)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(hostPath, []byte(data), 0644)
+	if err != nil {
+		return errors.New("Error: Could not write the file \"" + entry + "\" of the mount \"" + mount.source + "\".")
+	}
+	return nil
+}
+
+// Get the names of the entries of an archive
+// @param mount: *Mount - The mount of the archive
+// @return []string - The names, directories ending with a slash
+// @return map[string]int64 - The sizes of the files, by entry
+// @return error - The error if the archive cannot be read
+func (mount *Mount) _archiveNames() ([]string, map[string]int64, error) {
+
+	names := []string{}
+	sizes := map[string]int64{}
+
+	if mount.kind == MOUNT_ZIP {
+		archive, err := zip.OpenReader(mount.hostPath())
+		if err != nil {
+			return nil, nil, errors.New("Error: Could not open the archive \"" + mount.source + "\".")
+		}
+		defer archive.Close()
+
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+			sizes[_cleanEntry(file.Name)] = int64(file.UncompressedSize64)
+		}
+		return names, sizes, nil
+	}
+
+	err := mount._walkTar(func(header *tar.Header, reader io.Reader) bool {
+		switch header.Typeflag {
+		case tar.TypeDir:
+			names = append(names, strings.TrimSuffix(header.Name, "/")+"/")
+		case tar.TypeReg, tar.TypeRegA:
+			names = append(names, header.Name)
+			sizes[_cleanEntry(header.Name)] = header.Size
+		}
+		return true
+	})
+	return names, sizes, err
+}
+
+// Go through the entries of a tar archive, compressed or not
+// @param mount: *Mount - The mount of the archive
+// @param visit: func(*tar.Header, io.Reader) bool - Called for every entry, returns false to stop
+// @return error - The error if the archive cannot be read
+func (mount *Mount) _walkTar(visit func(*tar.Header, io.Reader) bool) error {
+
+	file, err := os.Open(mount.hostPath())
+	if err != nil {
+		return errors.New("Error: Could not open the archive \"" + mount.source + "\".")
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	lower := strings.ToLower(mount.source)
+	if strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		compressed, err := gzip.NewReader(file)
+		if err != nil {
+			return errors.New("Error: Could not decompress the archive \"" + mount.source + "\".")
+		}
+		defer compressed.Close()
+		reader = compressed
+	}
+
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New("Error: Could not read the archive \"" + mount.source + "\".")
+		}
+		if !visit(header, archive) {
+			return nil
+		}
+	}
+}
+
+// Clean the name of an archive entry
+// Entries trying to leave the archive are kept inside of it
+// @param name: string - The name of the entry
+// @return string - The entry, without leading or trailing slashes
+func _cleanEntry(name string) string {
+	return strings.Trim(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
+
+// Build the listing of every directory of an archive
+// Archives may leave out their directories, which are made up from the files
+// @param names: []string - The names of the entries of the archive
+// @return map[string]([]MountEntry) - The entries of each directory
+func _indexArchive(names []string) map[string]([]MountEntry) {
+
+	seen := map[string]bool{}
+	index := map[string]([]MountEntry){}
+
+	add := func(entry string, dir bool) {
+		if entry == "" || seen[entry] {
+			return
+		}
+		seen[entry] = true
+		parent, name := path.Split(entry)
+		parent = strings.TrimSuffix(parent, "/")
+		index[parent] = append(index[parent], MountEntry{name, dir})
+	}
+
+	for _, name := range names {
+		entry := _cleanEntry(name)
+		dir := strings.HasSuffix(name, "/")
+
+		// Make up the directories leading to the entry
+		parts := strings.Split(entry, "/")
+		for i := 1; i < len(parts); i++ {
+			add(strings.Join(parts[:i], "/"), true)
+		}
+		add(entry, dir)
+	}
+
+	for _, entries := range index {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	}
+	return index
+}
+
+// Load the children of a mounted folder the first time it is accessed
+// @param folder: *Folder - The folder
+func expandFolder(folder *Folder) {
+
+	node := folder.mounted
+	if node == nil || node.loaded {
+		return
+	}
+
+	entries, err := node.mount.list(node.entry)
+	if err != nil {
+		return
+	}
+	node.loaded = true
+
+	childPath := folder.path + folder.name + "/"
+	for _, entry := range entries {
+		child := &MountNode{node.mount, strings.TrimPrefix(node.entry+"/"+entry.name, "/"), false}
+		if entry.dir {
+			folder.folders[entry.name] = &Folder{entry.name, childPath, map[string]*Folder{}, map[string]*File{}, []string{"*", "*"}, false, "", map[string]string{}, false, "", false, child, []string{}, Metadata{}}
+		} else {
+			folder.files[entry.name] = &File{entry.name, childPath, "", "", []string{"*", "*"}, false, "", map[string]string{}, false, "", false, "", child, "", Metadata{}, nil}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// Max number of attempts to send a message
+	OUTBOUND_MAX_ATTEMPTS = 5
+
+	// Delay before the first retry, doubled after each attempt
+	OUTBOUND_RETRY_DELAY = 500 * time.Millisecond
+)
+
+// Structure of a message waiting to be sent
+// Text messages are formatted when sent, so consecutive ones can be merged
+type Outbound struct {
+	text   string
+	color  string
+	send   *discordgo.MessageSend
+	files  map[string][]byte
+	onSent func(*discordgo.Message)
+}
+
+// Structure of the messages waiting to be sent to a channel
+type OutboundQueue struct {
+	messages []*Outbound
+	running  bool
+}
+
+// Structure of the counters of the outbound messages
+type OutboundMetrics struct {
+	queued    int
+	sent      int
+	coalesced int
+	retried   int
+	failed    int
+}
+
+var (
+	// Messages waiting to be sent, by channel
+	outboundQueues map[string](*OutboundQueue) = map[string](*OutboundQueue){}
+
+	// Counters of the outbound messages
+	outboundMetrics OutboundMetrics
+
+	// Guards the outbound queues and their counters
+	outboundMutex sync.Mutex
+)
+
+// **DISCORD FEATURE ONLY**
+// Queue a message to be sent to a channel
+// Messages are sent in the order they were queued, one channel at a time
+// @param session: *discordgo.Session - The discord session to use
+// @param channelId: string - The channel to send the message to
+// @param message: *Outbound - The message to send
+func queueMessage(session *discordgo.Session, channelId string, message *Outbound) {
+	outboundMutex.Lock()
+	defer outboundMutex.Unlock()
+
+	queue, ok := outboundQueues[channelId]
+	if !ok {
+		queue = &OutboundQueue{}
+		outboundQueues[channelId] = queue
+	}
+
+	queue.messages = append(queue.messages, message)
+	outboundMetrics.queued++
+
+	if !queue.running {
+		queue.running = true
+		go _drainQueue(session, channelId, queue)
+	}
+}
+
+// Send the messages of a queue until it is empty
+// @param session: *discordgo.Session - The discord session to use
+// @param channelId: string - The channel of the queue
+// @param queue: *OutboundQueue - The queue
+func _drainQueue(session *discordgo.Session, channelId string, queue *OutboundQueue) {
+	for {
+		outboundMutex.Lock()
+		if len(queue.messages) == 0 {
+			queue.running = false
+			delete(outboundQueues, channelId)
+			outboundMutex.Unlock()
+			return
+		}
+		message := _coalesce(queue)
+		outboundMutex.Unlock()
+
+		sent, err := _sendWithRetry(session, channelId, message)
+
+		outboundMutex.Lock()
+		if err != nil {
+			outboundMetrics.failed++
+		} else {
+			outboundMetrics.sent++
+		}
+		outboundMutex.Unlock()
+
+		if err != nil {
+			fmt.Println("Error: Could not send a response message,", err)
+		} else if message.onSent != nil {
+			message.onSent(sent)
+		}
+	}
+}
+
+// Take the next message of a queue, merged with the text messages following it
+// Must be called with the outbound mutex held
+// @param queue: *OutboundQueue - The queue
+// @return *Outbound - The message to send
+func _coalesce(queue *OutboundQueue) *Outbound {
+
+	message := queue.messages[0]
+	queue.messages = queue.messages[1:]
+	if message.send != nil {
+		return message
+	}
+
+	merged := &Outbound{message.text, message.color, nil, nil, nil}
+	for len(queue.messages) > 0 {
+		next := queue.messages[0]
+		if next.send != nil || next.color != merged.color || len(merged.text)+len(next.text)+1 > PAGE_SIZE {
+			break
+		}
+		merged.text += "\n" + next.text
+		queue.messages = queue.messages[1:]
+		outboundMetrics.coalesced++
+	}
+
+	return merged
+}
+
+// Send a message, retrying when Discord is rate limiting or failing
+// @param session: *discordgo.Session - The discord session to use
+// @param channelId: string - The channel to send the message to
+// @param message: *Outbound - The message to send
+// @return *discordgo.Message - The message sent
+// @return error - The error of the last attempt if every attempt failed
+func _sendWithRetry(session *discordgo.Session, channelId string, message *Outbound) (*discordgo.Message, error) {
+
+	delay := OUTBOUND_RETRY_DELAY
+	for attempt := 1; ; attempt++ {
+
+		send := message.send
+		if send == nil {
+			send = &discordgo.MessageSend{Content: "```" + message.color + message.text + "```"}
+		}
+
+		// Files are read again on every attempt
+		if len(message.files) > 0 {
+			copied := *send
+			copied.Files = []*discordgo.File{}
+			for name, data := range message.files {
+				copied.Files = append(copied.Files, &discordgo.File{Name: name, Reader: bytes.NewReader(data)})
+			}
+			sort.Slice(copied.Files, func(i, j int) bool { return copied.Files[i].Name < copied.Files[j].Name })
+			send = &copied
+		}
+
+		sent, err := session.ChannelMessageSendComplex(channelId, send)
+		if err == nil {
+			return sent, nil
+		}
+
+		wait, retry := _retryDelay(err, delay)
+		if !retry || attempt >= OUTBOUND_MAX_ATTEMPTS {
+			return nil, err
+		}
+
+		outboundMutex.Lock()
+		outboundMetrics.retried++
+		outboundMutex.Unlock()
+
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
+
+// Determine if a failed message should be sent again, and when
+// @param err: error - The error of the attempt
+// @param delay: time.Duration - The delay of the exponential backoff
+// @return time.Duration - How long to wait before the next attempt
+// @return bool - True if the message should be sent again, false otherwise
+func _retryDelay(err error, delay time.Duration) (time.Duration, bool) {
+
+	var rateLimit *discordgo.RateLimitError
+	if errors.As(err, &rateLimit) {
+		return rateLimit.RetryAfter, true
+	}
+
+	var restError *discordgo.RESTError
+	if errors.As(err, &restError) && restError.Response != nil {
+		status := restError.Response.StatusCode
+		if status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+			return delay, true
+		}
+		return 0, false
+	}
+
+	// Network errors are worth another try
+	return delay, true
+}
+
+// Draw the counters of the outbound messages
+// @return string - The counters
+func drawOutboundMetrics() string {
+	outboundMutex.Lock()
+	defer outboundMutex.Unlock()
+
+	waiting := 0
+	for _, queue := range outboundQueues {
+		waiting += len(queue.messages)
+	}
+
+	output := fmt.Sprintf("Queued:    %d\n", outboundMetrics.queued)
+	output += fmt.Sprintf("Sent:      %d\n", outboundMetrics.sent)
+	output += fmt.Sprintf("Coalesced: %d\n", outboundMetrics.coalesced)
+	output += fmt.Sprintf("Retried:   %d\n", outboundMetrics.retried)
+	output += fmt.Sprintf("Failed:    %d\n", outboundMetrics.failed)
+	output += fmt.Sprintf("Waiting:   %d in %d channel(s)\n", waiting, len(outboundQueues))
+	return output
+}
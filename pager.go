@@ -0,0 +1,270 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// Max number of characters on a page of output
+	PAGE_SIZE = 1900
+
+	// Max number of paginated messages remembered
+	MAX_PAGERS = 200
+
+	// Number of lines printed at once by head and tail
+	DEFAULT_HEAD_LINES = 10
+)
+
+// Structure of a paginated message
+type Pager struct {
+	pages   []string
+	color   string
+	current int
+}
+
+var (
+	// Paginated messages, by message ID
+	pagers map[string](*Pager) = map[string](*Pager){}
+
+	// Paginated messages, from the oldest to the newest
+	pagerOrder []string
+
+	// Guards the paginated messages
+	pagerMutex sync.Mutex
+)
+
+// Split a text into pages on line boundaries
+// Lines longer than a page are split as well
+// @param text: string - The text to split
+// @param size: int - The max number of characters on a page
+// @return []string - The pages
+func paginate(text string, size int) []string {
+
+	pages := []string{}
+	page := ""
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+
+		// Split lines that would never fit on a page, without cutting a character in half
+		for len(line) > size {
+			if page != "" {
+				pages = append(pages, page)
+				page = ""
+			}
+			cut := size
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = size
+			}
+			pages = append(pages, line[:cut])
+			line = line[cut:]
+		}
+
+		if len(page)+len(line) > size {
+			pages = append(pages, page)
+			page = ""
+		}
+		page += line
+	}
+
+	if page != "" || len(pages) == 0 {
+		pages = append(pages, page)
+	}
+
+	return pages
+}
+
+// Format a page of a paginated message
+// @param pager: *Pager - The paginated message
+// @return string - The content of the message
+func (pager *Pager) content() string {
+	return "```" + pager.color + pager.pages[pager.current] + "```" + fmt.Sprintf("Page %d/%d", pager.current+1, len(pager.pages))
+}
+
+// Create the buttons used to change pages
+// @param pager: *Pager - The paginated message
+// @return []discordgo.MessageComponent - The buttons
+func (pager *Pager) buttons() []discordgo.MessageComponent {
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "Previous", Style: discordgo.SecondaryButton, CustomID: "page:prev", Disabled: pager.current == 0},
+			discordgo.Button{Label: "Next", Style: discordgo.SecondaryButton, CustomID: "page:next", Disabled: pager.current == len(pager.pages)-1},
+		}},
+	}
+}
+
+// Remember a paginated message so its buttons keep working
+// @param messageID: string - The ID of the message sent
+// @param pager: *Pager - The paginated message
+func storePager(messageID string, pager *Pager) {
+	pagerMutex.Lock()
+	defer pagerMutex.Unlock()
+
+	pagers[messageID] = pager
+	pagerOrder = append(pagerOrder, messageID)
+
+	// Forget the oldest messages
+	for len(pagerOrder) > MAX_PAGERS {
+		delete(pagers, pagerOrder[0])
+		pagerOrder = pagerOrder[1:]
+	}
+}
+
+// Send a paginated message to a channel
+// @param session: *discordgo.Session - The discord session to use
+// @param channelId: string - The channel to send the message to
+// @param pager: *Pager - The paginated message
+func sendPager(session *discordgo.Session, channelId string, pager *Pager) {
+	queueMessage(session, channelId, &Outbound{"", pager.color, &discordgo.MessageSend{
+		Content:    pager.content(),
+		Components: pager.buttons(),
+	}, nil, func(message *discordgo.Message) {
+		storePager(message.ID, pager)
+	}})
+}
+
+// Event handler for the page buttons of a paginated message
+// @param session: *discordgo.Session - The discord session to use
+// @param interaction: *discordgo.InteractionCreate - The button pressed
+func turnPage(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+
+	pagerMutex.Lock()
+	pager, ok := pagers[interaction.Message.ID]
+	if ok {
+		switch interaction.MessageComponentData().CustomID {
+		case "page:prev":
+			if pager.current > 0 {
+				pager.current--
+			}
+		case "page:next":
+			if pager.current < len(pager.pages)-1 {
+				pager.current++
+			}
+		}
+	}
+	pagerMutex.Unlock()
+
+	if !ok {
+		session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: "This output has expired.", Flags: uint64(discordgo.MessageFlagsEphemeral)},
+		})
+		return
+	}
+
+	session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Content: pager.content(), Components: pager.buttons()},
+	})
+}
+
+// Select a range of lines of a text
+// @param text: string - The text
+// @param from: int - The first line, starting at 1. Negative values count from the end.
+// @param count: int - The number of lines, or -1 for every remaining line
+// @param numbered: bool - Whether the lines are prefixed with their number
+// @return string - The selected lines
+func selectLines(text string, from int, count int, numbered bool) string {
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+
+	start := from - 1
+	if from < 0 {
+		start = len(lines) + from
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > len(lines) {
+		start = len(lines)
+	}
+
+	end := len(lines)
+	if count >= 0 && start+count < end {
+		end = start + count
+	}
+
+	width := len(strconv.Itoa(end))
+	output := ""
+	for i := start; i < end; i++ {
+		if numbered {
+			output += fmt.Sprintf("%*d  ", width, i+1)
+		}
+		output += lines[i] + "\n"
+	}
+
+	return output
+}
+
+// Parse the arguments of the head and tail commands
+// @param commands: []string - The command line
+// @return string - The path to the file
+// @return int - The number of lines
+// @return bool - True if the lines are numbered with -N, false otherwise
+// @return error - The error if the arguments are invalid
+func parseLineCount(commands []string) (string, int, bool, error) {
+
+	path := ""
+	count := DEFAULT_HEAD_LINES
+	numbered := false
+	for i := 1; i < len(commands); i++ {
+		if commands[i] == "-n" && i+1 < len(commands) {
+			value, err := strconv.Atoi(commands[i+1])
+			if err != nil || value < 0 {
+				return "", 0, false, errors.New("Error: Invalid number of lines \"" + commands[i+1] + "\".")
+			}
+			count = value
+			i++
+		} else if commands[i] == "-N" {
+			numbered = true
+		} else {
+			path = commands[i]
+		}
+	}
+
+	if path == "" {
+		return "", 0, false, errors.New("Error: No file was specified. Expecting \"" + commands[0] + " [-n lines] [-N] <file>\".")
+	}
+
+	return path, count, numbered, nil
+}
+
+// **CLI FEATURE ONLY**
+// Print a text to the terminal, through a pager if it does not fit on the screen
+// @param text: string - The text to print
+func cliPrint(text string) {
+
+	height := 24
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		height = lines
+	}
+
+	if strings.Count(text, "\n") < height-1 {
+		fmt.Println(text)
+		return
+	}
+
+	pager := os.Getenv("PAGER")
+	if pager == "" {
+		pager = "more"
+	}
+
+	cmd := exec.Command(pager)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Println(text)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kinds of errors met while resolving a path
+var (
+	errPathNotFound     = errors.New("not found")
+	errPathLocked       = errors.New("locked")
+	errPathUnavailable  = errors.New("unavailable")
+	errPathNotDirectory = errors.New("not a directory")
+	errPathNotFile      = errors.New("not a file")
+	errPathLinkLoop     = errors.New("too many levels of links")
+)
+
+// Structure of an error met while resolving a path
+// Use errors.Is with the kinds above to tell them apart
+type PathError struct {
+	kind error
+	path string
+}
+
+func (err *PathError) Error() string {
+	switch err.kind {
+	case errPathNotFound:
+		return "Error: Could not find \"" + err.path + "\"."
+	case errPathLocked:
+		return "Error: \"" + err.path + "\" is locked."
+	case errPathUnavailable:
+		return "Error: \"" + err.path + "\" is unavailable at the moment."
+	case errPathNotDirectory:
+		return "Error: \"" + err.path + "\" is not a directory."
+	case errPathNotFile:
+		return "Error: \"" + err.path + "\" is not a file."
+	case errPathLinkLoop:
+		return "Error: Too many levels of links at \"" + err.path + "\"."
+	}
+	return "Error: Invalid path \"" + err.path + "\"."
+}
+
+func (err *PathError) Unwrap() error {
+	return err.kind
+}
+
+// Turn a path into a clean absolute path
+// Handles "~", ".", "..", repeated and trailing slashes and quoted names
+// @param path: string - The path, absolute or relative
+// @param channel: string - The channel whose current directory relative paths start from, or empty to start from the root
+// @return string - The absolute path, "/" for the root
+func normalizePath(path string, channel string) string {
+
+	path = _unquote(strings.TrimSpace(path))
+
+	// Find where the path starts from
+	base := "/"
+	switch {
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		path = path[1:]
+	case strings.HasPrefix(path, "/"):
+	case channel != "":
+		if getCurrentDirectory(channel) != nil {
+			base = getCurrentDirectoryPath(channel)
+		}
+	}
+
+	segments := []string{}
+	for _, segment := range strings.Split(base+"/"+path, "/") {
+		segment = _unquote(segment)
+		switch segment {
+		case "", ".":
+		case "..":
+			// The root is its own parent
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+
+	return "/" + strings.Join(segments, "/")
+}
+
+// Split a clean absolute path into its directory and its last name
+// @param path: string - The absolute path
+// @return string - The directory, "/" for the root
+// @return string - The last name, empty for the root
+func splitPath(path string) (string, string) {
+	index := strings.LastIndex(path, "/")
+	if index <= 0 {
+		return "/", path[index+1:]
+	}
+	return path[:index], path[index+1:]
+}
+
+// Check a name given to a file or a folder from outside of the environment
+// Names cannot hold path separators, quotes or control characters
+// @param name: string - The name
+// @return error - The error if the name cannot be used
+func checkName(name string) error {
+	if strings.TrimSpace(name) == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\"") {
+		return errors.New("Error: Invalid name \"" + name + "\".")
+	}
+	for _, char := range name {
+		if unicode.IsControl(char) {
+			return errors.New("Error: Invalid name " + strconv.Quote(name) + ".")
+		}
+	}
+	return nil
+}
+
+// Remove the quotes around a name
+// @param name: string - The name
+// @return string - The name without its quotes
+func _unquote(name string) string {
+	if len(name) >= 2 && (name[0] == '"' || name[0] == '\'') && name[len(name)-1] == name[0] {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// Find the folder or the file at a path
+// Links are followed, and every directory on the way must be open
+// @param path: string - The path, absolute or relative
+// @param channel: string - The channel whose environment and current directory are used
+// @param relative: bool - Whether relative paths start from the current directory rather than the root
+// @param checkLast: bool - Whether the element found must be open too
+// @return *Folder - The folder found, if any
+// @return *File - The file found, if any
+// @return error - The *PathError if nothing open was found
+func resolvePath(path string, channel string, relative bool, checkLast bool) (*Folder, *File, error) {
+	from := ""
+	if relative {
+		from = channel
+	}
+	return _resolve(normalizePath(path, from), channel, checkLast, 0)
+}
+
+// Find the folder or the file at a clean absolute path
+// @param path: string - The absolute path
+// @param channel: string - The channel whose environment is used
+// @param checkLast: bool - Whether the element found must be open too
+// @param depth: int - The number of links followed so far
+// @return *Folder - The folder found, if any
+// @return *File - The file found, if any
+// @return error - The *PathError if nothing open was found
+func _resolve(path string, channel string, checkLast bool, depth int) (*Folder, *File, error) {
+
+	currDir := &channelEnvironment(channel).root
+	if path == "/" {
+		return currDir, nil, nil
+	}
+
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, name := range segments {
+		last := i == len(segments)-1
+		walked := "/" + strings.Join(segments[:i+1], "/")
+
+		expandFolder(currDir)
+		folder, isFolder := (*currDir).folders[name]
+		file, isFile := (*currDir).files[name]
+
+		switch {
+		case isFolder:
+			if !last || checkLast {
+				if err := _checkOpen(walked, folder.availableBetween, folder.locked); err != nil {
+					return nil, nil, err
+				}
+			}
+			if last {
+				return folder, nil, nil
+			}
+			currDir = folder
+
+		case isFile && file.link != "":
+			// Links are checked through their target
+			if depth >= MAX_LINK_DEPTH {
+				return nil, nil, &PathError{errPathLinkLoop, walked}
+			}
+			targetFolder, targetFile, err := _resolve(linkTarget(file), channel, !last || checkLast, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			if last {
+				return targetFolder, targetFile, nil
+			}
+			if targetFolder == nil {
+				return nil, nil, &PathError{errPathNotDirectory, walked}
+			}
+			currDir = targetFolder
+
+		case isFile:
+			if !last {
+				return nil, nil, &PathError{errPathNotDirectory, walked}
+			}
+			if checkLast {
+				if err := _checkOpen(walked, file.availableBetween, file.locked); err != nil {
+					return nil, nil, err
+				}
+			}
+			return nil, file, nil
+
+		default:
+			return nil, nil, &PathError{errPathNotFound, path}
+		}
+	}
+
+	return currDir, nil, nil
+}
+
+// Check that an element can be accessed
+// @param path: string - The path of the element
+// @param availableBetween: []string - The availability window of the element
+// @param locked: bool - Whether the element is locked
+// @return error - The *PathError if the element is closed
+func _checkOpen(path string, availableBetween []string, locked bool) error {
+	if !IsTimeAvailable(availableBetween[0], availableBetween[1]) {
+		return &PathError{errPathUnavailable, path}
+	}
+	if locked {
+		return &PathError{errPathLocked, path}
+	}
+	return nil
+}
+
+// Find the folder or the file at a path without following a link or checking the element itself
+// Only the directories on the way must be open, so closed elements and links can be described
+// @param path: string - The path, absolute or relative
+// @param channel: string - The channel whose environment and current directory are used
+// @return *Folder - The folder found, if any
+// @return *File - The file or link found, if any
+// @return error - The *PathError if nothing was found
+func lookupPath(path string, channel string) (*Folder, *File, error) {
+
+	path = normalizePath(path, channel)
+	if path == "/" {
+		return &channelEnvironment(channel).root, nil, nil
+	}
+
+	dirPath, name := splitPath(path)
+	parent, err := getDirectory(dirPath, false, channel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expandFolder(parent)
+	if folder, ok := (*parent).folders[name]; ok {
+		return folder, nil, nil
+	}
+	if file, ok := (*parent).files[name]; ok {
+		return nil, file, nil
+	}
+	return nil, nil, &PathError{errPathNotFound, path}
+}
@@ -10,9 +10,16 @@ import (
 func HasPermission(session *discordgo.Session, member *discordgo.Member, guildID string, permission string) bool {
 	guild := GetGuild(session, guildID)
 
+	// The bot itself only runs the scheduled tasks of the environment file, which are always allowed
+	if member != nil && member.User != nil && session.State.User != nil && member.User.ID == session.State.User.ID {
+		return true
+	}
+
+	permissions := guildEnvironment(guildID).permissions
+
 	// Check general permissions for everyone
-	if _, ok := systemPermissions["everyone"]; ok {
-		for _, perm := range systemPermissions["everyone"] {
+	if _, ok := permissions["everyone"]; ok {
+		for _, perm := range permissions["everyone"] {
 			if perm == permission {
 				return true
 			} else if perm == "*" {
@@ -21,13 +28,18 @@ func HasPermission(session *discordgo.Session, member *discordgo.Member, guildID
 		}
 	}
 
+	// Users outside of a guild have no roles
+	if member == nil {
+		return false
+	}
+
 	// Check for role permissions
 	for _, roleId := range (*member).Roles {
 		roleName := GetRoleName(guild, roleId)
 
-		if _, ok := systemPermissions[roleName]; ok {
+		if _, ok := permissions[roleName]; ok {
 
-			for _, perm := range systemPermissions[roleName] {
+			for _, perm := range permissions[roleName] {
 				if perm == permission {
 					return true
 				} else if perm == "*" {
@@ -67,8 +79,8 @@ func GetGuild(session *discordgo.Session, guildID string) *discordgo.Guild {
 	return nil
 }
 
-func GetRolePriority(roleName string) int {
-	for i, role := range priorityPermissions {
+func GetRolePriority(roleName string, guildID string) int {
+	for i, role := range guildEnvironment(guildID).priority {
 		if role == roleName {
 			return i
 		}
@@ -84,8 +96,8 @@ func GetUserHighestPriorityRole(session *discordgo.Session, member *discordgo.Me
 	for _, roleId := range (*member).Roles {
 		roleName := GetRoleName(guild, roleId)
 
-		if GetRolePriority(roleName) > highestPriority {
-			highestPriority = GetRolePriority(roleName)
+		if GetRolePriority(roleName, guildID) > highestPriority {
+			highestPriority = GetRolePriority(roleName, guildID)
 			highestPriorityRole = roleName
 		}
 	}
@@ -104,7 +116,7 @@ func HasRole(session *discordgo.Session, member *discordgo.Member, guildID strin
 
 func subscribeToRole(session *discordgo.Session, member *discordgo.Member, guildID string, userID string, roleName string) error {
 
-	newRolePriority := GetRolePriority(roleName)
+	newRolePriority := GetRolePriority(roleName, guildID)
 	if newRolePriority == -1 {
 		return errors.New("Error: Role \"" + roleName + "\" not found.")
 	}
@@ -115,7 +127,7 @@ func subscribeToRole(session *discordgo.Session, member *discordgo.Member, guild
 		memberCurrentRole = "everyone"
 	}
 
-	if newRolePriority >= GetRolePriority(memberCurrentRole) {
+	if newRolePriority >= GetRolePriority(memberCurrentRole, guildID) {
 		return errors.New("Error: You do not have permission to subscribe to this role.")
 	}
 
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Access states of a file or folder
+const (
+	NODE_OK          = "ok"
+	NODE_LOCKED      = "locked"
+	NODE_UNAVAILABLE = "unavailable"
+)
+
+// Colors of the Discord embeds
+const (
+	EMBED_RED    = 0xe74c3c
+	EMBED_GREEN  = 0x2ecc71
+	EMBED_BLUE   = 0x3498db
+	EMBED_YELLOW = 0xf1c40f
+	EMBED_GREY   = 0x95a5a6
+)
+
+// ANSI escape codes for the command line interface
+const (
+	ANSI_RESET  = "\033[0m"
+	ANSI_BOLD   = "\033[1m"
+	ANSI_RED    = "\033[31m"
+	ANSI_GREEN  = "\033[32m"
+	ANSI_YELLOW = "\033[33m"
+	ANSI_BLUE   = "\033[34m"
+	ANSI_CYAN   = "\033[36m"
+)
+
+// Structure of an entry of a directory listing
+type TreeEntry struct {
+	name      string
+	path      string
+	folder    bool
+	state     string
+	children  []*TreeEntry
+	truncated bool
+	target    string
+}
+
+// Structure of a rendered command result
+// Text backends only fill the text, the Discord backend may use an embed instead
+type Rendered struct {
+	text  string
+	embed *discordgo.MessageEmbed
+}
+
+// Output backend turning command results into something to show
+type Renderer interface {
+	renderMessage(color string, text string) Rendered
+	renderTree(tree *TreeEntry) Rendered
+}
+
+// Backend for Discord, using embeds for listings and errors
+type EmbedRenderer struct{}
+
+// Backend for the command line interface, using ANSI colors
+type AnsiRenderer struct{}
+
+// Backend without any formatting, for logs and tests
+type PlainRenderer struct{}
+
+var (
+	// Backend used for Discord output
+	discordRenderer Renderer = EmbedRenderer{}
+
+	// Backend used for the command line interface
+	cliRenderer Renderer = newCliRenderer()
+)
+
+// Choose the command line backend depending on the output
+// @return Renderer - ANSI colors for terminals, plain text otherwise
+func newCliRenderer() Renderer {
+	info, err := os.Stdout.Stat()
+	if err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		return AnsiRenderer{}
+	}
+	return PlainRenderer{}
+}
+
+// Get the access state of a file or folder
+// @param availableBetween: []string - The availability window
+// @param locked: bool - Whether it is locked
+// @return string - The access state
+func nodeState(availableBetween []string, locked bool) string {
+	if locked {
+		return NODE_LOCKED
+	}
+	if !IsTimeAvailable(availableBetween[0], availableBetween[1]) {
+		return NODE_UNAVAILABLE
+	}
+	return NODE_OK
+}
+
+// Draw the lines of a directory tree
+// @param tree: *TreeEntry - The directory tree
+// @param prefix: string - The prefix to use before each line of the tree
+// @param label: func(*TreeEntry) string - Formats the name of an entry
+// @return []string - The directory tree as a list of lines
+func drawTreeLines(tree *TreeEntry, prefix string, label func(*TreeEntry) string) []string {
+
+	lines := []string{}
+
+	if tree.truncated {
+		return append(lines, prefix+"└─(...)")
+	}
+
+	for i, child := range tree.children {
+
+		// Reached the last entry
+		last := i == len(tree.children)-1
+		connector, indent := "├─", "| "
+		if last {
+			connector, indent = "└─", "  "
+		}
+
+		lines = append(lines, prefix+connector+label(child))
+
+		// Draw sub folder contents
+		if child.folder && child.state == NODE_OK {
+			lines = append(lines, drawTreeLines(child, prefix+indent, label)...)
+		}
+	}
+
+	return lines
+}
+
+// Count the entries of a directory tree
+// @param tree: *TreeEntry - The directory tree
+// @return int - The number of folders
+// @return int - The number of files
+// @return int - The number of locked or unavailable entries
+func countTree(tree *TreeEntry) (int, int, int) {
+	folders, files, closed := 0, 0, 0
+	for _, child := range tree.children {
+		if child.state != NODE_OK {
+			closed++
+		}
+		if child.folder {
+			folders++
+			subFolders, subFiles, subClosed := countTree(child)
+			folders += subFolders
+			files += subFiles
+			closed += subClosed
+		} else {
+			files++
+		}
+	}
+	return folders, files, closed
+}
+
+// Label an entry with its name and state, as text
+// @param entry: *TreeEntry - The entry
+// @return string - The label
+func plainLabel(entry *TreeEntry) string {
+	label := entry.name
+	if entry.folder {
+		label += "/"
+	}
+	if entry.target != "" {
+		label += " -> " + entry.target
+	}
+	if entry.state != NODE_OK {
+		label += " (" + entry.state + ")"
+	}
+	return label
+}
+
+func (PlainRenderer) renderMessage(color string, text string) Rendered {
+	return Rendered{text, nil}
+}
+
+func (PlainRenderer) renderTree(tree *TreeEntry) Rendered {
+	output := tree.name + "/\n|\n"
+	for _, line := range drawTreeLines(tree, "", plainLabel) {
+		output += line + "\n"
+	}
+	return Rendered{output, nil}
+}
+
+func (AnsiRenderer) renderMessage(color string, text string) Rendered {
+	switch color {
+	case COLOR_RED:
+		return Rendered{ANSI_RED + text + ANSI_RESET, nil}
+	case COLOR_GREEN:
+		return Rendered{ANSI_GREEN + text + ANSI_RESET, nil}
+	case COLOR_YELLOW:
+		return Rendered{ANSI_YELLOW + text + ANSI_RESET, nil}
+	case COLOR_BLUE:
+		return Rendered{ANSI_BLUE + text + ANSI_RESET, nil}
+	}
+	return Rendered{text, nil}
+}
+
+func (AnsiRenderer) renderTree(tree *TreeEntry) Rendered {
+	lines := drawTreeLines(tree, "", func(entry *TreeEntry) string {
+		switch {
+		case entry.state == NODE_LOCKED:
+			return ANSI_RED + plainLabel(entry) + ANSI_RESET
+		case entry.state == NODE_UNAVAILABLE:
+			return ANSI_YELLOW + plainLabel(entry) + ANSI_RESET
+		case entry.folder:
+			return ANSI_BOLD + ANSI_BLUE + plainLabel(entry) + ANSI_RESET
+		case entry.target != "":
+			return ANSI_CYAN + plainLabel(entry) + ANSI_RESET
+		}
+		return plainLabel(entry)
+	})
+	output := ANSI_BOLD + ANSI_BLUE + tree.name + "/" + ANSI_RESET + "\n|\n"
+	for _, line := range lines {
+		output += line + "\n"
+	}
+	return Rendered{output, nil}
+}
+
+func (EmbedRenderer) renderMessage(color string, text string) Rendered {
+
+	// Errors stand out as embeds, other messages stay in code blocks
+	if color == COLOR_RED && len(text) < 4000 {
+		return Rendered{text, &discordgo.MessageEmbed{
+			Title:       "⛔ Error",
+			Description: strings.TrimPrefix(text, "Error: "),
+			Color:       EMBED_RED,
+		}}
+	}
+
+	return Rendered{text, nil}
+}
+
+func (EmbedRenderer) renderTree(tree *TreeEntry) Rendered {
+
+	lines := drawTreeLines(tree, "", func(entry *TreeEntry) string {
+		icon := "📄 "
+		if entry.folder {
+			icon = "📁 "
+		} else if entry.target != "" {
+			icon = "🔗 "
+		}
+		switch entry.state {
+		case NODE_LOCKED:
+			icon = "🔒 "
+		case NODE_UNAVAILABLE:
+			icon = "⏳ "
+		}
+		return icon + plainLabel(entry)
+	})
+	text := strings.Join(lines, "\n")
+	if text == "" {
+		text = "(empty)"
+	}
+
+	// Listings too long for an embed are paginated as text
+	if len(text) > 4000 {
+		return Rendered{PlainRenderer{}.renderTree(tree).text, nil}
+	}
+
+	folders, files, closed := countTree(tree)
+	return Rendered{text, &discordgo.MessageEmbed{
+		Title:       "📁 " + tree.path,
+		Description: "```\n" + text + "\n```",
+		Color:       EMBED_BLUE,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Folders", Value: strconv.Itoa(folders), Inline: true},
+			{Name: "Files", Value: strconv.Itoa(files), Inline: true},
+			{Name: "Locked or unavailable", Value: strconv.Itoa(closed), Inline: true},
+		},
+	}}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Default number of levels drawn by tree and ls
+	DEFAULT_TREE_DEPTH = 5
+
+	// Max number of levels drawn by tree
+	MAX_TREE_DEPTH = 16
+
+	// Default max number of results of find and grep
+	DEFAULT_SEARCH_RESULTS = 50
+)
+
+// Parse the arguments of the tree command
+// @param commands: []string - The command line
+// @return string - The directory to draw, "." when not given
+// @return int - The number of levels to draw
+// @return error - The error if the arguments are invalid
+func parseTreeArgs(commands []string) (string, int, error) {
+
+	target := "."
+	depth := DEFAULT_TREE_DEPTH
+	for i := 1; i < len(commands); i++ {
+		if commands[i] == "-L" && i+1 < len(commands) {
+			value, err := strconv.Atoi(commands[i+1])
+			if err != nil || value < 1 || value > MAX_TREE_DEPTH {
+				return "", 0, fmt.Errorf("Error: Invalid depth \"%s\". Expecting a number between 1 and %d.", commands[i+1], MAX_TREE_DEPTH)
+			}
+			depth = value
+			i++
+		} else {
+			target = commands[i]
+		}
+	}
+
+	return target, depth, nil
+}
+
+// Parse the arguments of the find command
+// @param commands: []string - The command line
+// @return string - The directory to search
+// @return string - The glob the names must match
+// @return string - The type of the results: "f" for files, "d" for directories, "l" for links, empty for any
+// @return error - The error if the arguments are invalid
+func parseFindArgs(commands []string) (string, string, string, error) {
+
+	target, glob, kind := "", "*", ""
+	for i := 1; i < len(commands); i++ {
+		switch {
+		case commands[i] == "-name" && i+1 < len(commands):
+			glob = commands[i+1]
+			i++
+		case commands[i] == "-type" && i+1 < len(commands):
+			kind = commands[i+1]
+			i++
+		default:
+			target = commands[i]
+		}
+	}
+
+	if target == "" {
+		return "", "", "", errors.New("Error: No directory was specified. Expecting \"find <path> -name <glob> [-type f|d|l]\".")
+	}
+	if kind != "" && kind != "f" && kind != "d" && kind != "l" {
+		return "", "", "", errors.New("Error: Invalid type \"" + kind + "\". Expecting \"f\", \"d\" or \"l\".")
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return "", "", "", errors.New("Error: Invalid pattern \"" + glob + "\".")
+	}
+
+	return target, glob, kind, nil
+}
+
+// Parse the arguments of the grep command
+// @param commands: []string - The command line
+// @return *regexp.Regexp - The pattern the lines must match
+// @return string - The file or directory to search
+// @return bool - Whether directories are searched
+// @return error - The error if the arguments are invalid
+func parseGrepArgs(commands []string) (*regexp.Regexp, string, bool, error) {
+
+	commands, recursive := popFlag(commands, "-r")
+	commands, ignoreCase := popFlag(commands, "-i")
+
+	if len(commands) < 3 {
+		return nil, "", false, errors.New("Error: No pattern or path was specified. Expecting \"grep [-r] [-i] <pattern> <path>\".")
+	}
+
+	expression := commands[1]
+	if ignoreCase {
+		expression = "(?i)" + expression
+	}
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, "", false, errors.New("Error: Invalid pattern \"" + commands[1] + "\".")
+	}
+
+	return pattern, commands[2], recursive, nil
+}
+
+// Go through the elements of a directory a viewer can see and access
+// Hidden, locked and unavailable elements are skipped with their contents, and links are not followed
+// @param directory: *Folder - The directory to explore
+// @param viewer: *Viewer - Who is searching
+// @param visit: func(*Folder, *File) bool - Called for every folder or file, returns false to stop
+// @return bool - False if the exploration was stopped
+func walkVisible(directory *Folder, viewer *Viewer, visit func(*Folder, *File) bool) bool {
+
+	for _, folder := range visibleFolders(directory, viewer) {
+		if nodeState(folder.availableBetween, folder.locked) != NODE_OK {
+			continue
+		}
+		if !visit(folder, nil) || !walkVisible(folder, viewer, visit) {
+			return false
+		}
+	}
+
+	for _, file := range visibleFiles(directory, viewer) {
+		if nodeState(file.availableBetween, file.locked) != NODE_OK {
+			continue
+		}
+		if !visit(nil, file) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Find the elements of a directory whose name matches a glob
+// The number of results is capped by "search_max_results" in the configuration file
+// @param directory: *Folder - The directory to search
+// @param glob: string - The glob the names must match
+// @param kind: string - The type of the results: "f", "d", "l" or empty for any
+// @param viewer: *Viewer - Who is searching
+// @return string - The paths found, one per line
+func drawFind(directory *Folder, glob string, kind string, viewer *Viewer) string {
+
+	limit := configInt("search_max_results", DEFAULT_SEARCH_RESULTS)
+	results := []string{}
+	truncated := false
+
+	walkVisible(directory, viewer, func(folder *Folder, file *File) bool {
+		name, line, elementKind := "", "", ""
+		switch {
+		case folder != nil:
+			name, line, elementKind = folder.name, getFolderPath(folder)+"/", "d"
+		case file.link != "":
+			name, line, elementKind = file.name, file.path+file.name+" -> "+file.link, "l"
+		default:
+			name, line, elementKind = file.name, file.path+file.name, "f"
+		}
+
+		if kind != "" && kind != elementKind {
+			return true
+		}
+		if matched, _ := path.Match(glob, name); !matched {
+			return true
+		}
+
+		if len(results) >= limit {
+			truncated = true
+			return false
+		}
+		results = append(results, line)
+		return true
+	})
+
+	if len(results) == 0 {
+		return "No match found.\n"
+	}
+
+	output := strings.Join(results, "\n") + "\n"
+	if truncated {
+		output += fmt.Sprintf("(stopped after %d results)\n", limit)
+	}
+	return output
+}
+
+// Find the lines of files matching a pattern
+// Binary files are only reported, and the number of results is capped by "search_max_results"
+// @param files: []*File - The files to search
+// @param pattern: *regexp.Regexp - The pattern the lines must match
+// @param showPath: bool - Whether the lines start with the path of their file
+// @return string - The matching lines
+func drawGrep(files []*File, pattern *regexp.Regexp, showPath bool) string {
+
+	limit := configInt("search_max_results", DEFAULT_SEARCH_RESULTS)
+	results := []string{}
+
+	for _, file := range files {
+		data, err := readFile(file)
+		if err != nil {
+			continue
+		}
+
+		prefix := ""
+		if showPath {
+			prefix = file.path + file.name + ":"
+		}
+
+		if isBinary(data) {
+			if pattern.Match(data) {
+				results = append(results, "Binary file "+file.path+file.name+" matches")
+			}
+		} else {
+			for i, line := range strings.Split(string(data), "\n") {
+				if pattern.MatchString(line) {
+					results = append(results, fmt.Sprintf("%s%d: %s", prefix, i+1, line))
+				}
+				if len(results) > limit {
+					break
+				}
+			}
+		}
+
+		if len(results) > limit {
+			return strings.Join(results[:limit], "\n") + fmt.Sprintf("\n(stopped after %d results)\n", limit)
+		}
+	}
+
+	if len(results) == 0 {
+		return "No match found.\n"
+	}
+	return strings.Join(results, "\n") + "\n"
+}
+
+// Get the files searched by grep
+// @param target: string - The file or directory to search
+// @param recursive: bool - Whether directories are searched
+// @param channel: string - The channel used to resolve relative paths
+// @param viewer: *Viewer - Who is searching
+// @return []*File - The files to search
+// @return bool - Whether several files may be searched
+// @return error - The error if the path cannot be searched
+func grepFiles(target string, recursive bool, channel string, viewer *Viewer) ([]*File, bool, error) {
+
+	folder, file, err := resolvePath(target, channel, true, true)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if file != nil {
+		return []*File{file}, false, nil
+	}
+
+	if !recursive {
+		return nil, false, &PathError{errPathNotFile, normalizePath(target, channel)}
+	}
+
+	files := []*File{}
+	walkVisible(folder, viewer, func(_ *Folder, file *File) bool {
+		if file != nil && file.link == "" {
+			files = append(files, file)
+		}
+		return true
+	})
+	return files, true, nil
+}
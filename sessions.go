@@ -0,0 +1,611 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tidwall/gjson"
+)
+
+// Kinds of terminal sessions
+const (
+	SESSION_CHANNEL        = "channel"
+	SESSION_PUBLIC_THREAD  = "thread"
+	SESSION_PRIVATE_THREAD = "private"
+)
+
+const (
+	// Default max number of sessions a user can have open at once
+	DEFAULT_SESSIONS_PER_USER = 2
+
+	// Default number of minutes of inactivity before a thread is archived
+	DEFAULT_THREAD_ARCHIVE_MINUTES = 60
+
+	// Default number of minutes of inactivity before a session is closed
+	DEFAULT_SESSION_IDLE_MINUTES = 120
+
+	// Number of minutes before an idle session is closed that its users are warned
+	SESSION_WARNING_MINUTES = 10
+)
+
+// Structure of a terminal session opened with "new"
+type Session struct {
+	channel      string
+	kind         string
+	parent       string
+	owner        string
+	created      time.Time
+	lastActivity time.Time
+	variables    map[string](*SystemVariable)
+	warned       bool
+	members      []string
+}
+
+var (
+	// Open sessions, by channel or thread ID
+	sessions map[string](*Session) = map[string](*Session){}
+
+	// Guards the open sessions
+	sessionsMutex sync.Mutex
+)
+
+// Get the session opened in a channel
+// @param channel: string - The channel or thread ID
+// @return *Session - The session, or nil if the channel is not a session
+func getSession(channel string) *Session {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	return sessions[channel]
+}
+
+// Register a new session
+// @param session: *Session - The session to register
+func openSession(session *Session) {
+	sessionsMutex.Lock()
+	sessions[session.channel] = session
+	sessionsMutex.Unlock()
+
+	inheritChannel(session.channel, session.parent)
+	setCurrentDirectory(session.channel, &channelEnvironment(session.channel).root)
+	saveSessions()
+}
+
+// Forget a session and its state
+// Running jobs of the session report to its parent channel from now on
+// @param channel: string - The channel or thread ID of the session
+// @return *Session - The session removed, or nil if there was none
+// @return int - The number of running jobs handed over to the parent channel
+func endSession(channel string) (*Session, int) {
+	sessionsMutex.Lock()
+	session, ok := sessions[channel]
+	delete(sessions, channel)
+	sessionsMutex.Unlock()
+
+	if !ok {
+		return nil, 0
+	}
+
+	clearCurrentDirectory(channel)
+	saveSessions()
+	return session, reparentJobs(channel, session.parent)
+}
+
+// Close a session, deleting its channel or archiving its thread
+// @param session: *discordgo.Session - The discord session to use
+// @param channel: string - The channel or thread ID of the session
+// @return error - The error if the channel is not a session or could not be closed
+func closeSession(session *discordgo.Session, channel string) error {
+
+	// Hand running jobs over to the parent focus channel
+	closed, moved := endSession(channel)
+	if closed == nil {
+		return errors.New("Error: Cannot close this channel.")
+	}
+	if moved > 0 {
+		echo(session, closed.parent, fmt.Sprintf("Channel closed. %d running job(s) now report here.", moved), COLOR_YELLOW)
+	}
+
+	// Archive threads, so their history stays available
+	if closed.kind != SESSION_CHANNEL {
+		_, err := session.ChannelEditComplex(channel, &discordgo.ChannelEdit{Archived: true, Locked: true})
+		if err != nil {
+			return errors.New("Error: Failed to archive thread.")
+		}
+		return nil
+	}
+
+	// Delete channel
+	_, err := session.ChannelDelete(channel)
+	if err != nil {
+		return errors.New("Error: Failed to delete channel.")
+	}
+	return nil
+}
+
+// Mark a session as used
+// @param channel: string - The channel or thread ID of the session
+func touchSession(channel string) {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	if session, ok := sessions[channel]; ok {
+		session.lastActivity = time.Now()
+		session.warned = false
+	}
+}
+
+// Count the open sessions
+// @param owner: string - Only count the sessions of this user, or every session if empty
+// @param kind: string - Only count the sessions of this kind, or every kind if empty
+// @param env: *Environment - Only count the sessions of this environment, or every session if nil
+// @return int - The number of sessions
+func countSessions(owner string, kind string, env *Environment) int {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	count := 0
+	for _, session := range sessions {
+		if (owner == "" || session.owner == owner) && (kind == "" || session.kind == kind) && (env == nil || channelEnvironment(session.channel) == env) {
+			count++
+		}
+	}
+	return count
+}
+
+// Get the focus channel a session should be opened from
+// Sessions opened from other sessions share their focus channel
+// @param channel: string - The channel the session is opened from
+// @return string - The focus channel
+func sessionParent(channel string) string {
+	if session := getSession(channel); session != nil {
+		return session.parent
+	}
+	return channel
+}
+
+// Open a session in a new thread under a focus channel
+// @param session: *discordgo.Session - The discord session to use
+// @param parent: string - The focus channel to open the thread in
+// @param owner: string - The user opening the session
+// @param kind: string - SESSION_PUBLIC_THREAD or SESSION_PRIVATE_THREAD
+// @return *discordgo.Channel - The thread created
+// @return error - The error if the thread could not be created
+func openThreadSession(session *discordgo.Session, parent string, owner string, kind string) (*discordgo.Channel, error) {
+
+	threadType := discordgo.ChannelTypeGuildPublicThread
+	if kind == SESSION_PRIVATE_THREAD {
+		threadType = discordgo.ChannelTypeGuildPrivateThread
+	}
+
+	threadName := NewChannelName(5)
+	thread, err := session.ThreadStartComplex(parent, &discordgo.ThreadStart{
+		Name:                threadName,
+		AutoArchiveDuration: configInt("thread_archive_minutes", DEFAULT_THREAD_ARCHIVE_MINUTES),
+		Type:                threadType,
+		Invitable:           false,
+	})
+	if err != nil {
+		return nil, errors.New("Error: Failed to create thread \"" + threadName + "\".")
+	}
+
+	// Private threads are only visible to their members
+	if kind == SESSION_PRIVATE_THREAD {
+		err = session.ThreadMemberAdd(thread.ID, owner)
+		if err != nil {
+			session.ChannelDelete(thread.ID)
+			return nil, errors.New("Error: Failed to add you to thread \"" + threadName + "\".")
+		}
+	}
+
+	now := time.Now()
+	openSession(&Session{thread.ID, kind, parent, owner, now, now, map[string](*SystemVariable){}, false, []string{}})
+	return thread, nil
+}
+
+// Determine if a user can use the session opened in a channel
+// Channels that are not sessions can be used by everyone
+// @param channel: string - The channel or thread ID
+// @param userID: string - The user
+// @return bool - True if the user owns the session or was invited to it, false otherwise
+func canUseSession(channel string, userID string) bool {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+
+	session, ok := sessions[channel]
+	if !ok || session.owner == userID {
+		return true
+	}
+	for _, member := range session.members {
+		if member == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Get a user ID from a mention or a raw ID
+// @param user: string - The mention or ID
+// @return string - The user ID
+func parseUserMention(user string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(user, "<@"), "!"), ">")
+}
+
+// Restrict a new session channel to its owner and the bot
+// @param session: *discordgo.Session - The discord session to use
+// @param guildID: string - The guild of the channel
+// @param channel: string - The channel of the session
+// @param owner: string - The owner of the session
+// @return error - The error if the permissions could not be set
+func restrictSessionChannel(session *discordgo.Session, guildID string, channel string, owner string) error {
+	access := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages)
+
+	// The everyone role shares its ID with the guild
+	err := session.ChannelPermissionSet(channel, guildID, discordgo.PermissionOverwriteTypeRole, 0, discordgo.PermissionViewChannel)
+	if err != nil {
+		return err
+	}
+	err = session.ChannelPermissionSet(channel, session.State.User.ID, discordgo.PermissionOverwriteTypeMember, access, 0)
+	if err != nil {
+		return err
+	}
+	return session.ChannelPermissionSet(channel, owner, discordgo.PermissionOverwriteTypeMember, access, 0)
+}
+
+// Let a user into a session
+// @param session: *discordgo.Session - The discord session to use
+// @param channel: string - The channel or thread ID of the session
+// @param userID: string - The user to invite
+// @return error - The error if the user could not be invited
+func inviteToSession(session *discordgo.Session, channel string, userID string) error {
+
+	opened := getSession(channel)
+	if opened == nil {
+		return errors.New("Error: This channel is not a session.")
+	}
+	if canUseSession(channel, userID) {
+		return errors.New("Error: The user can already use this session.")
+	}
+
+	// Give access to the channel or thread on Discord
+	var err error
+	switch opened.kind {
+	case SESSION_CHANNEL:
+		err = session.ChannelPermissionSet(channel, userID, discordgo.PermissionOverwriteTypeMember, int64(discordgo.PermissionViewChannel|discordgo.PermissionSendMessages), 0)
+	case SESSION_PRIVATE_THREAD:
+		err = session.ThreadMemberAdd(channel, userID)
+	}
+	if err != nil {
+		return errors.New("Error: Could not give the user access to this session.")
+	}
+
+	sessionsMutex.Lock()
+	opened.members = append(opened.members, userID)
+	sessionsMutex.Unlock()
+	saveSessions()
+	return nil
+}
+
+// Remove a user from a session
+// @param session: *discordgo.Session - The discord session to use
+// @param channel: string - The channel or thread ID of the session
+// @param userID: string - The user to remove
+// @return error - The error if the user could not be removed
+func kickFromSession(session *discordgo.Session, channel string, userID string) error {
+
+	opened := getSession(channel)
+	if opened == nil {
+		return errors.New("Error: This channel is not a session.")
+	}
+	if opened.owner == userID {
+		return errors.New("Error: The owner cannot be removed from their session.")
+	}
+
+	sessionsMutex.Lock()
+	found := false
+	for i, member := range opened.members {
+		if member == userID {
+			opened.members = append(opened.members[:i], opened.members[i+1:]...)
+			found = true
+			break
+		}
+	}
+	sessionsMutex.Unlock()
+	if !found {
+		return errors.New("Error: The user was not invited to this session.")
+	}
+	saveSessions()
+
+	// Take the access to the channel or thread back on Discord
+	var err error
+	switch opened.kind {
+	case SESSION_CHANNEL:
+		err = session.ChannelPermissionDelete(channel, userID)
+	case SESSION_PRIVATE_THREAD:
+		err = session.ThreadMemberRemove(channel, userID)
+	}
+	if err != nil {
+		return errors.New("Error: Could not take the access to this session back.")
+	}
+	return nil
+}
+
+// Event handler for updated threads
+// Threads archived by Discord after inactivity end their session
+func onThreadUpdate(session *discordgo.Session, update *discordgo.ThreadUpdate) {
+	if update.ThreadMetadata == nil || !update.ThreadMetadata.Archived {
+		return
+	}
+	if ended, _ := endSession(update.ID); ended != nil {
+		echo(session, ended.parent, "Session \""+update.Name+"\" was archived after inactivity.", COLOR_YELLOW)
+	}
+}
+
+// Event handler for deleted channels and threads
+func onChannelDelete(session *discordgo.Session, channel *discordgo.ChannelDelete) {
+	endSession(channel.ID)
+}
+
+// Event handler for deleted threads
+func onThreadDelete(session *discordgo.Session, thread *discordgo.ThreadDelete) {
+	endSession(thread.ID)
+}
+
+// Get a variable as seen from a channel
+// Variables of the channel's session hide the global ones
+// @param name: string - The name of the variable
+// @param channel: string - The channel the variable is used from
+// @return *SystemVariable - The variable
+// @return error - The error if the variable does not exist
+func getVariable(name string, channel string) (*SystemVariable, error) {
+	if variable := sessionVariable(name, channel); variable != nil {
+		return variable, nil
+	}
+	return getSystemVariable(name, channelEnvironment(channel))
+}
+
+// Determine if a variable exists, as seen from a channel
+// @param name: string - The name of the variable
+// @param channel: string - The channel the variable is used from
+// @return bool - True if the variable exists, false otherwise
+func variableExists(name string, channel string) bool {
+	return sessionVariable(name, channel) != nil || systemVariableExists(name, channelEnvironment(channel))
+}
+
+// Set a variable, as seen from a channel
+// @param name: string - The name of the variable
+// @param value: string - The new value
+// @param channel: string - The channel the variable is used from
+// @return error - The error if the variable does not exist or is immutable
+func setVariable(name string, value string, channel string) error {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	if session, ok := sessions[channel]; ok {
+		if variable, ok := session.variables[name]; ok {
+			variable.Value = value
+			return nil
+		}
+	}
+	return setSystemVariable(name, value, channelEnvironment(channel))
+}
+
+// Create a variable from a channel
+// Variables created from threads only exist in their session
+// @param name: string - The name of the variable
+// @param value: string - The value of the variable
+// @param channel: string - The channel the variable is created from
+// @return error - The error if the variable already exists
+func createVariable(name string, value string, channel string) error {
+	if variableExists(name, channel) {
+		return errors.New("Error: The variable \"" + name + "\" already exists.")
+	}
+
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	if session, ok := sessions[channel]; ok && session.kind != SESSION_CHANNEL {
+		session.variables[name] = &SystemVariable{name, false, value}
+		return nil
+	}
+	return createSystemVariable(name, value, channelEnvironment(channel))
+}
+
+// Delete a variable, as seen from a channel
+// @param name: string - The name of the variable
+// @param channel: string - The channel the variable is deleted from
+// @return error - The error if the variable does not exist or cannot be deleted
+func deleteVariable(name string, channel string) error {
+	sessionsMutex.Lock()
+	if session, ok := sessions[channel]; ok {
+		if _, ok := session.variables[name]; ok {
+			delete(session.variables, name)
+			sessionsMutex.Unlock()
+			return nil
+		}
+	}
+	sessionsMutex.Unlock()
+	return deleteSystemVariable(name, channelEnvironment(channel))
+}
+
+// Get a variable of the session of a channel
+// @param name: string - The name of the variable
+// @param channel: string - The channel of the session
+// @return *SystemVariable - The variable, or nil if the session does not have it
+func sessionVariable(name string, channel string) *SystemVariable {
+	sessionsMutex.Lock()
+	defer sessionsMutex.Unlock()
+	if session, ok := sessions[channel]; ok {
+		return session.variables[name]
+	}
+	return nil
+}
+
+// Save the open sessions to the sessions file
+func saveSessions() {
+
+	sessionsMutex.Lock()
+	state := []map[string]interface{}{}
+	for _, session := range sessions {
+		variables := map[string]string{}
+		for name, variable := range session.variables {
+			variables[name] = variable.Value
+		}
+		state = append(state, map[string]interface{}{
+			"channel":      session.channel,
+			"kind":         session.kind,
+			"parent":       session.parent,
+			"owner":        session.owner,
+			"created":      session.created.Unix(),
+			"lastActivity": session.lastActivity.Unix(),
+			"variables":    variables,
+			"members":      session.members,
+		})
+	}
+	sessionsMutex.Unlock()
+
+	data, _ := json.Marshal(state)
+	err := os.WriteFile(*sessionsPath, data, 0644)
+	if err != nil {
+		fmt.Println("Error: Could not save the sessions,", err)
+	}
+}
+
+// Reattach the sessions saved in the sessions file
+// Sessions whose channel or thread no longer exists are dropped
+// @param session: *discordgo.Session - The discord session to use
+func loadSessions(session *discordgo.Session) {
+
+	data, err := ioutil.ReadFile(*sessionsPath)
+	if err != nil {
+		return
+	}
+
+	for _, element := range gjson.ParseBytes(data).Array() {
+
+		channelID := element.Get("channel").String()
+		channel, err := session.Channel(channelID)
+		if err != nil || (channel.ThreadMetadata != nil && channel.ThreadMetadata.Archived) {
+			fmt.Printf("Dropping session \"%s\", its channel is gone.\n", channelID)
+			continue
+		}
+
+		variables := map[string](*SystemVariable){}
+		for name, value := range element.Get("variables").Map() {
+			variables[name] = &SystemVariable{name, false, value.String()}
+		}
+
+		members := []string{}
+		for _, member := range element.Get("members").Array() {
+			members = append(members, member.String())
+		}
+
+		sessionsMutex.Lock()
+		sessions[channelID] = &Session{
+			channelID,
+			element.Get("kind").String(),
+			element.Get("parent").String(),
+			element.Get("owner").String(),
+			time.Unix(element.Get("created").Int(), 0),
+			time.Unix(element.Get("lastActivity").Int(), 0),
+			variables,
+			false,
+			members,
+		}
+		sessionsMutex.Unlock()
+		bindChannel(channelID, channel.GuildID)
+		setCurrentDirectory(channelID, &channelEnvironment(channelID).root)
+	}
+
+	fmt.Printf("Reattached %d session(s).\n", countSessions("", "", nil))
+	saveSessions()
+}
+
+// Warn and close the sessions left idle for too long, every minute
+// The timeout is set by "session_idle_minutes" in the configuration file, 0 disabling it
+// @param session: *discordgo.Session - The discord session to use
+func startSessionReaper(session *discordgo.Session) {
+
+	timeout := time.Duration(configInt("session_idle_minutes", DEFAULT_SESSION_IDLE_MINUTES)) * time.Minute
+	if timeout <= 0 {
+		return
+	}
+	warning := timeout - SESSION_WARNING_MINUTES*time.Minute
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+
+			idle, warn := []string{}, []string{}
+			sessionsMutex.Lock()
+			for channel, s := range sessions {
+				switch {
+				case time.Since(s.lastActivity) >= timeout:
+					idle = append(idle, channel)
+				case time.Since(s.lastActivity) >= warning && !s.warned:
+					s.warned = true
+					warn = append(warn, channel)
+				}
+			}
+			sessionsMutex.Unlock()
+
+			for _, channel := range warn {
+				echo(session, channel, fmt.Sprintf("This session will be closed in %d minutes unless it is used.", SESSION_WARNING_MINUTES), COLOR_YELLOW)
+			}
+			for _, channel := range idle {
+				if parent := sessionParent(channel); closeSession(session, channel) == nil {
+					echo(session, parent, "Closed a session left idle for "+timeout.String()+".", COLOR_YELLOW)
+				}
+			}
+
+			// Keep the last activity times on disk
+			saveSessions()
+		}
+	}()
+}
+
+// Draw the list of open sessions of a guild
+// Names are taken from the state cache rather than requested for every session
+// @param session: *discordgo.Session - The discord session to use
+// @param guildID: string - The guild whose sessions are listed
+// @return string - The list of sessions
+func drawSessions(session *discordgo.Session, guildID string) string {
+	sessionsMutex.Lock()
+	opened := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		opened = append(opened, *s)
+	}
+	sessionsMutex.Unlock()
+
+	output := ""
+	for _, s := range opened {
+
+		// Sessions of unknown channels are left out rather than shown to another guild
+		channel, err := session.State.Channel(s.channel)
+		if err != nil {
+			channel, err = session.State.Channel(s.parent)
+		}
+		if err != nil || channel.GuildID != guildID {
+			continue
+		}
+
+		// Use names when they are known
+		name, owner := s.channel, s.owner
+		if channel.ID == s.channel {
+			name = channel.Name
+		}
+		if member, err := session.State.Member(guildID, s.owner); err == nil && member.User != nil {
+			owner = member.User.Username
+		}
+
+		output += fmt.Sprintf("%-12s %-8s %-16s created %s, idle for %s\n", name, s.kind, owner, s.created.Format("2006-01-02 15:04"), time.Since(s.lastActivity).Round(time.Minute))
+	}
+
+	if output == "" {
+		return "No sessions."
+	}
+
+	return output
+}
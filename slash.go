@@ -0,0 +1,554 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Structure of a slash command waiting for its replies
+type SlashReply struct {
+	interaction *discordgo.Interaction
+	ephemeral   bool
+	sent        bool
+}
+
+var (
+	// Slash commands registered in every guild
+	slashCommands = []*discordgo.ApplicationCommand{
+		{Name: "help", Description: "Shows the help message."},
+		{Name: "echo", Description: "Prints a message.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "message", Description: "The message to print", Required: true},
+		}},
+		{Name: "pwd", Description: "Prints the current working directory."},
+		{Name: "ls", Description: "Prints the current directory structure.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "all", Description: "Show hidden files and folders"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "long", Description: "Show the details of each element"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "onelevel", Description: "Leave out the contents of the folders"},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The directory to list, or a pattern like docs/*.txt", Autocomplete: true},
+		}},
+		{Name: "tree", Description: "Draw a directory tree.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The directory to draw", Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "depth", Description: "The number of levels to draw"},
+		}},
+		{Name: "find", Description: "Find files and folders by name.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The directory to search", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "The pattern of the names, like *.txt"},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "type", Description: "The type of the results", Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "file", Value: "f"},
+				{Name: "directory", Value: "d"},
+				{Name: "link", Value: "l"},
+			}},
+		}},
+		{Name: "grep", Description: "Find the lines of files matching a pattern.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "pattern", Description: "The regular expression to match", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file or directory to search", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "recursive", Description: "Search the files of directories"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "ignorecase", Description: "Ignore the case of the letters"},
+		}},
+		{Name: "cd", Description: "Navigate into a directory.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The directory to go to", Required: true, Autocomplete: true},
+		}},
+		{Name: "cat", Description: "Preview the contents of a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file to preview", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "numbered", Description: "Number the lines"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "hex", Description: "Dump the bytes of the file"},
+		}},
+		{Name: "decode", Description: "Print the decoded contents of a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "encoding", Description: "The encoding of the file", Required: true, Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "base64", Value: "base64"},
+				{Name: "hex", Value: "hex"},
+			}},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file to decode", Required: true, Autocomplete: true},
+		}},
+		{Name: "head", Description: "Print the first lines of a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file to preview", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "lines", Description: "The number of lines"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "numbered", Description: "Number the lines"},
+		}},
+		{Name: "tail", Description: "Print the last lines of a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file to preview", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "lines", Description: "The number of lines"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "numbered", Description: "Number the lines"},
+		}},
+		{Name: "grab", Description: "Download a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file to download", Required: true, Autocomplete: true},
+		}},
+		{Name: "ln", Description: "Create a link to a file or a directory.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "target", Description: "The path the link leads to", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "link", Description: "The path of the new link", Required: true},
+		}},
+		{Name: "stat", Description: "Show the details of a file or a folder.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file or folder", Required: true, Autocomplete: true},
+		}},
+		{Name: "log", Description: "List the versions kept for a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file", Required: true, Autocomplete: true},
+		}},
+		{Name: "diff", Description: "Show the changes made to a file since one of its versions.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "version", Description: "The version to compare, the previous one by default"},
+		}},
+		{Name: "revert", Description: "Bring back an earlier version of a file.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "version", Description: "The version to bring back, like v3", Required: true},
+		}},
+		{Name: "export", Description: "Download a file or a directory as a zip archive.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file or directory, the current directory by default", Autocomplete: true},
+		}},
+		{Name: "import", Description: "Unpack a zip archive into a directory.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionAttachment, Name: "archive", Description: "The zip archive", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The directory receiving the contents, the current directory by default", Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "conflict", Description: "What to do with the elements that already exist", Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "skip", Value: "skip"},
+				{Name: "overwrite", Value: "overwrite"},
+				{Name: "rename", Value: "rename"},
+			}},
+		}},
+		{Name: "upload", Description: "Store a file in the directory tree.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionAttachment, Name: "file", Description: "The file to store", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "Where to store the file", Autocomplete: true},
+		}},
+		{Name: "get", Description: "Get the value of a variable.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "The variable", Required: true},
+		}},
+		{Name: "set", Description: "Set or create a variable.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "The variable", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "value", Description: "The new value", Required: true},
+		}},
+		{Name: "delete", Description: "Delete a variable.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "The variable", Required: true},
+		}},
+		{Name: "su", Description: "Manage user roles.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "action", Description: "Subscribe or unsubscribe", Required: true, Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "subscribe", Value: "subscribe"},
+				{Name: "unsubscribe", Value: "unsubscribe"},
+			}},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "role", Description: "The role", Required: true},
+		}},
+		{Name: "lock", Description: "Lock a vault.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file or folder to lock", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "key", Description: "The key of the vault", Required: true},
+		}},
+		{Name: "unlock", Description: "Unlock a vault.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The file or folder to unlock", Required: true, Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "key", Description: "The key of the vault", Required: true},
+		}},
+		{Name: "save", Description: "Save the environment.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "file", Description: "The environment file", Required: true},
+		}},
+		{Name: "gc", Description: "Delete the cache files no environment file uses anymore."},
+		{Name: "load", Description: "Load an environment.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "file", Description: "The environment file", Required: true},
+		}},
+		{Name: "new", Description: "Open a new CLI session.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "type", Description: "Where to open the session", Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "channel", Value: SESSION_CHANNEL},
+				{Name: "public thread", Value: SESSION_PUBLIC_THREAD},
+				{Name: "private thread", Value: SESSION_PRIVATE_THREAD},
+			}},
+		}},
+		{Name: "close", Description: "Close the current CLI session."},
+		{Name: "sessions", Description: "List the open sessions."},
+		{Name: "netstat", Description: "Show the counters of the outbound messages."},
+		{Name: "guild", Description: "Manage the environment of the guild.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "action", Description: "What to do", Required: true, Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "info", Value: "info"},
+				{Name: "provision", Value: "provision"},
+			}},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "template", Description: "The environment file to copy"},
+		}},
+		{Name: "invite", Description: "Let a user into the current session.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "The user to invite", Required: true},
+		}},
+		{Name: "kick", Description: "Remove a user from the current session.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "The user to remove", Required: true},
+		}},
+		{Name: "run", Description: "Run a Kode program.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "path", Description: "The program file to run", Autocomplete: true},
+			{Type: discordgo.ApplicationCommandOptionAttachment, Name: "program", Description: "The program to run"},
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "background", Description: "Run the program in the background"},
+		}},
+		{Name: "jobs", Description: "List the background jobs of the channel."},
+		{Name: "fg", Description: "Wait for a background job to end.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "job", Description: "The job", Required: true},
+		}},
+		{Name: "kill", Description: "Stop a background job.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "job", Description: "The job", Required: true},
+		}},
+		{Name: "wait", Description: "Wait for one or all background jobs to end.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "job", Description: "The job"},
+		}},
+		{Name: "cron", Description: "Manage scheduled tasks.", Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionString, Name: "action", Description: "What to do", Required: true, Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "list", Value: "list"},
+				{Name: "add", Value: "add"},
+				{Name: "rm", Value: "rm"},
+			}},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "The task"},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "schedule", Description: "When the task runs"},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "command", Description: "The command to run"},
+		}},
+	}
+
+	// Command line flags set by boolean options, or placed before the value of other options, by command
+	slashFlags = map[string]map[string]string{
+		"ls":     {"all": "-a", "long": "-l", "onelevel": "-1"},
+		"run":    {"background": "&"},
+		"cat":    {"numbered": "-n", "hex": "-x"},
+		"tree":   {"depth": "-L"},
+		"find":   {"name": "-name", "type": "-type"},
+		"grep":   {"recursive": "-r", "ignorecase": "-i"},
+		"head":   {"lines": "-n", "numbered": "-N"},
+		"tail":   {"lines": "-n", "numbered": "-N"},
+		"import": {"conflict": "-c"},
+	}
+
+	// Commands whose replies are only shown to the user
+	ephemeralCommands = map[string]bool{
+		"get":    true,
+		"unlock": true,
+	}
+
+	// Slash commands waiting for their replies, by interaction ID
+	slashReplies = map[string]*SlashReply{}
+
+	// Guards the slash commands waiting for their replies
+	slashMutex sync.Mutex
+)
+
+// Event handler for Discord interactions
+func onDiscordInteraction(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+
+	switch interaction.Type {
+	case discordgo.InteractionApplicationCommand:
+		runSlashCommand(session, interaction)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		autocompleteSlashCommand(session, interaction)
+	case discordgo.InteractionMessageComponent:
+		turnPage(session, interaction)
+	}
+}
+
+// Run a slash command as if it was typed in the channel
+// @param session: *discordgo.Session - The discord session to use
+// @param interaction: *discordgo.InteractionCreate - The slash command
+func runSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+
+	// Ignore all commands outside the configured channels
+	if interaction.Member == nil || !isTerminalChannel(interaction.ChannelID, interaction.GuildID) || !canUseSession(interaction.ChannelID, interaction.Member.User.ID) {
+		session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{Content: "KuriOS is not available in this channel.", Flags: uint64(discordgo.MessageFlagsEphemeral)},
+		})
+		return
+	}
+
+	data := interaction.ApplicationCommandData()
+	commands, attachments := slashCommandLine(data)
+
+	// Acknowledge the command while it runs
+	slashReply := &SlashReply{interaction.Interaction, ephemeralCommands[data.Name], false}
+	response := &discordgo.InteractionResponse{Type: discordgo.InteractionResponseDeferredChannelMessageWithSource}
+	if slashReply.ephemeral {
+		response.Data = &discordgo.InteractionResponseData{Flags: uint64(discordgo.MessageFlagsEphemeral)}
+	}
+	err := session.InteractionRespond(interaction.Interaction, response)
+	if err != nil {
+		fmt.Println("Error: Could not acknowledge the slash command,", err)
+		return
+	}
+
+	slashMutex.Lock()
+	slashReplies[interaction.ID] = slashReply
+	slashMutex.Unlock()
+
+	message := &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:          interaction.ID,
+		ChannelID:   interaction.ChannelID,
+		GuildID:     interaction.GuildID,
+		Content:     strings.Join(commands, " "),
+		Author:      interaction.Member.User,
+		Member:      interaction.Member,
+		Attachments: attachments,
+	}}
+	touchSession(interaction.ChannelID)
+	executeDiscordCommand(session, message, commands)
+
+	slashMutex.Lock()
+	delete(slashReplies, interaction.ID)
+	sent := slashReply.sent
+	slashMutex.Unlock()
+
+	// Remove the pending response of commands without output
+	if !sent {
+		session.InteractionResponseDelete(interaction.Interaction)
+	}
+}
+
+// Build the command line of a slash command
+// @param data: discordgo.ApplicationCommandInteractionData - The slash command data
+// @return []string - The command line
+// @return []*discordgo.MessageAttachment - The attachments given to the command
+func slashCommandLine(data discordgo.ApplicationCommandInteractionData) ([]string, []*discordgo.MessageAttachment) {
+
+	commands := []string{data.Name}
+	attachments := []*discordgo.MessageAttachment{}
+
+	// Find the declared options, in order
+	var declared []*discordgo.ApplicationCommandOption
+	for _, command := range slashCommands {
+		if command.Name == data.Name {
+			declared = command.Options
+			break
+		}
+	}
+
+	for _, option := range declared {
+		for _, value := range data.Options {
+			if value.Name != option.Name {
+				continue
+			}
+
+			// Name the value with its flag
+			if flag, ok := slashFlags[data.Name][value.Name]; ok && value.Type != discordgo.ApplicationCommandOptionBoolean {
+				commands = append(commands, flag)
+			}
+
+			switch value.Type {
+			case discordgo.ApplicationCommandOptionBoolean:
+				if value.BoolValue() {
+					commands = append(commands, slashFlags[data.Name][value.Name])
+				}
+			case discordgo.ApplicationCommandOptionInteger:
+				commands = append(commands, strconv.FormatInt(value.IntValue(), 10))
+			case discordgo.ApplicationCommandOptionAttachment:
+				if data.Resolved != nil {
+					if attachment, ok := data.Resolved.Attachments[value.Value.(string)]; ok {
+						attachments = append(attachments, attachment)
+					}
+				}
+			case discordgo.ApplicationCommandOptionUser:
+				commands = append(commands, value.Value.(string))
+			default:
+				commands = append(commands, value.StringValue())
+			}
+		}
+	}
+
+	return commands, attachments
+}
+
+// Suggest paths of the directory tree for the focused option
+// @param session: *discordgo.Session - The discord session to use
+// @param interaction: *discordgo.InteractionCreate - The autocomplete request
+func autocompleteSlashCommand(session *discordgo.Session, interaction *discordgo.InteractionCreate) {
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+
+	if interaction.Member != nil && isTerminalChannel(interaction.ChannelID, interaction.GuildID) {
+		for _, option := range interaction.ApplicationCommandData().Options {
+			if option.Focused && option.Type == discordgo.ApplicationCommandOptionString {
+				viewer := &Viewer{session, interaction.Member, interaction.GuildID, interaction.ChannelID, false}
+				for _, path := range completePath(option.StringValue(), interaction.ChannelID, viewer) {
+					choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: path, Value: path})
+				}
+			}
+		}
+	}
+
+	session.InteractionRespond(interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+}
+
+// List the paths of the directory tree starting with a partial path
+// @param partial: string - The path typed so far
+// @param channel: string - The channel used to resolve relative paths
+// @param viewer: *Viewer - Who is looking at the directory tree
+// @return []string - Up to 25 matching paths
+func completePath(partial string, channel string, viewer *Viewer) []string {
+
+	// Find the directory being completed
+	directory := getCurrentDirectory(channel)
+	base := ""
+	prefix := partial
+	if index := strings.LastIndex(partial, "/"); index >= 0 {
+		base = partial[:index+1]
+		prefix = partial[index+1:]
+
+		folder, err := getDirectory(base, true, channel)
+		if err != nil {
+			return []string{}
+		}
+		directory = folder
+	}
+
+	paths := []string{}
+	prefix = strings.ToLower(prefix)
+	for _, folder := range visibleFolders(directory, viewer) {
+		if strings.HasPrefix(strings.ToLower(folder.name), prefix) {
+			paths = append(paths, base+folder.name+"/")
+		}
+	}
+	for _, file := range visibleFiles(directory, viewer) {
+		if strings.HasPrefix(strings.ToLower(file.name), prefix) {
+			paths = append(paths, base+file.name)
+		}
+	}
+
+	// Discord shows at most 25 choices
+	if len(paths) > 25 {
+		paths = paths[:25]
+	}
+
+	return paths
+}
+
+// Get the slash command waiting for the replies of a message, if any
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @return *SlashReply - The slash command, or nil for regular messages
+func getSlashReply(message *discordgo.MessageCreate) *SlashReply {
+	slashMutex.Lock()
+	defer slashMutex.Unlock()
+
+	return slashReplies[message.ID]
+}
+
+// Remember that a slash command was answered
+// Replies may be sent from other goroutines, such as jobs in the foreground
+func (slashReply *SlashReply) markSent() {
+	slashMutex.Lock()
+	defer slashMutex.Unlock()
+
+	slashReply.sent = true
+}
+
+// Reply to a command with a message
+// Slash commands are answered through their interaction, other commands in their channel
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @param text: string - The message to print
+// @param color: string - The color of the message
+func reply(session *discordgo.Session, message *discordgo.MessageCreate, text string, color string) {
+	replyRendered(session, message, discordRenderer.renderMessage(color, text), color)
+}
+
+// Reply to a command with a directory tree
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @param tree: *TreeEntry - The directory tree
+func replyTree(session *discordgo.Session, message *discordgo.MessageCreate, tree *TreeEntry) {
+	replyRendered(session, message, discordRenderer.renderTree(tree), COLOR_WHITE)
+}
+
+// Reply to a command with a rendered result
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @param rendered: Rendered - The rendered result
+// @param color: string - The color of the text, if not sent as an embed
+func replyRendered(session *discordgo.Session, message *discordgo.MessageCreate, rendered Rendered, color string) {
+
+	slashReply := getSlashReply(message)
+	if slashReply == nil {
+		sendRendered(session, message.ChannelID, rendered, color)
+		return
+	}
+
+	params := &discordgo.WebhookParams{Content: "```" + color + rendered.text + "```"}
+	if slashReply.ephemeral {
+		params.Flags = uint64(discordgo.MessageFlagsEphemeral)
+	}
+
+	// Split long messages into pages
+	var pager *Pager
+	if rendered.embed != nil {
+		params.Content = ""
+		params.Embeds = []*discordgo.MessageEmbed{rendered.embed}
+	} else if pages := paginate(rendered.text, PAGE_SIZE); len(pages) > 1 {
+		pager = &Pager{pages, color, 0}
+		params.Content = pager.content()
+		params.Components = pager.buttons()
+	}
+
+	sent, err := session.FollowupMessageCreate(slashReply.interaction, true, params)
+	if err != nil {
+		fmt.Println("Error: Could not send a response message,", err)
+	} else if pager != nil {
+		storePager(sent.ID, pager)
+	}
+	slashReply.markSent()
+}
+
+// Reply to a command with a file
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @param name: string - The name of the file
+// @param reader: io.Reader - The contents of the file
+func replyFile(session *discordgo.Session, message *discordgo.MessageCreate, name string, reader io.Reader) {
+
+	slashReply := getSlashReply(message)
+	if slashReply == nil {
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			fmt.Println("Error: Could not send a response file,", err)
+			return
+		}
+		queueMessage(session, message.ChannelID, &Outbound{"", "", &discordgo.MessageSend{}, map[string][]byte{name: data}, nil})
+		return
+	}
+
+	params := &discordgo.WebhookParams{Files: []*discordgo.File{{Name: name, Reader: reader}}}
+	if slashReply.ephemeral {
+		params.Flags = uint64(discordgo.MessageFlagsEphemeral)
+	}
+
+	_, err := session.FollowupMessageCreate(slashReply.interaction, true, params)
+	if err != nil {
+		fmt.Println("Error: Could not send a response file,", err)
+	}
+	slashReply.markSent()
+}
+
+// Reply to a command with an image shown in an embed
+// @param session: *discordgo.Session - The discord session to use
+// @param message: *discordgo.MessageCreate - The message holding the command
+// @param name: string - The name of the image
+// @param data: []byte - The contents of the image
+func replyImage(session *discordgo.Session, message *discordgo.MessageCreate, name string, data []byte) {
+
+	// Embeds cannot refer to attachments with spaces in their name
+	name = strings.ReplaceAll(name, " ", "_")
+
+	embed := &discordgo.MessageEmbed{
+		Title:  "🖼 " + name,
+		Color:  EMBED_BLUE,
+		Image:  &discordgo.MessageEmbedImage{URL: "attachment://" + name},
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("%s, %d byte(s)", contentType(data), len(data))},
+	}
+
+	slashReply := getSlashReply(message)
+	if slashReply == nil {
+		queueMessage(session, message.ChannelID, &Outbound{"", "", &discordgo.MessageSend{Embed: embed}, map[string][]byte{name: data}, nil})
+		return
+	}
+
+	params := &discordgo.WebhookParams{
+		Embeds: []*discordgo.MessageEmbed{embed},
+		Files:  []*discordgo.File{{Name: name, Reader: bytes.NewReader(data)}},
+	}
+	if slashReply.ephemeral {
+		params.Flags = uint64(discordgo.MessageFlagsEphemeral)
+	}
+
+	_, err := session.FollowupMessageCreate(slashReply.interaction, true, params)
+	if err != nil {
+		fmt.Println("Error: Could not send a response image,", err)
+	}
+	slashReply.markSent()
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// Default max size of an uploaded file, in bytes
+	DEFAULT_UPLOAD_MAX_BYTES = 8 * 1024 * 1024
+
+	// Default max size of a text file stored inside the environment, in bytes
+	DEFAULT_UPLOAD_INLINE_BYTES = 16 * 1024
+
+	// Default directory holding the contents of the cache files
+	DEFAULT_CACHE_DIR = "cache"
+)
+
+// Default content types that can be uploaded
+var defaultUploadTypes = []string{"text/*", "image/*", "application/json", "application/pdf"}
+
+// **DISCORD FEATURE ONLY**
+// Store an attachment as a file of the directory tree
+// Small text files are kept in the environment, other files in the cache directory
+// @param attachment: *discordgo.MessageAttachment - The attachment to store
+// @param path: string - The path of the new file
+// @param channel: string - The channel used to resolve relative paths
+// @param author: string - The user ID of the uploader
+// @return *File - The file written
+// @return error - The error if the attachment cannot be stored
+func uploadAttachment(attachment *discordgo.MessageAttachment, path string, channel string, author string) (*File, error) {
+
+	data, err := downloadAttachment(attachment, configInt("upload_max_bytes", DEFAULT_UPLOAD_MAX_BYTES))
+	if err != nil {
+		return nil, err
+	}
+
+	// Trust the contents rather than the name of the file
+	contentType := http.DetectContentType(data)
+	if !uploadTypeAllowed(contentType) {
+		return nil, errors.New("Error: Files of type \"" + contentType + "\" cannot be uploaded.")
+	}
+
+	// Keep small text files inside the environment
+	if strings.HasPrefix(contentType, "text/") && utf8.Valid(data) && len(data) <= configInt("upload_inline_bytes", DEFAULT_UPLOAD_INLINE_BYTES) {
+		return writeFile(path, string(data), channel, author, "Uploaded "+attachment.Filename)
+	}
+
+	file, err := writeFile(path, "", channel, author, "Uploaded "+attachment.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files of mounted directories stay on the host
+	if file.mounted != nil {
+		return file, file.mounted.mount.write(file.mounted.entry, string(data))
+	}
+
+	cache, hash, err := storeBlob(data)
+	if err != nil {
+		return nil, err
+	}
+	file.cache = cache
+	file.hash = hash
+
+	return file, nil
+}
+
+// **DISCORD FEATURE ONLY**
+// Download the contents of an attachment
+// @param attachment: *discordgo.MessageAttachment - The attachment to download
+// @param maxBytes: int - The max size of the attachment, in bytes
+// @return []byte - The contents of the attachment
+// @return error - The error if the attachment is too large or cannot be downloaded
+func downloadAttachment(attachment *discordgo.MessageAttachment, maxBytes int) ([]byte, error) {
+
+	if attachment.Size > maxBytes {
+		return nil, fmt.Errorf("Error: The file \"%s\" is larger than %d bytes.", attachment.Filename, maxBytes)
+	}
+
+	// Never read more than allowed
+	resp, err := http.Get(attachment.URL)
+	if err != nil {
+		return nil, errors.New("Error: Failed to download attachment \"" + attachment.Filename + "\".")
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, errors.New("Error: Failed to read attachment \"" + attachment.Filename + "\".")
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("Error: The file \"%s\" is larger than %d bytes.", attachment.Filename, maxBytes)
+	}
+
+	return data, nil
+}
+
+// Determine if a content type can be uploaded
+// Allowed types are set by "upload_types" in the configuration file, "image/*" allowing every image
+// @param contentType: string - The detected content type
+// @return bool - True if the type is allowed, false otherwise
+func uploadTypeAllowed(contentType string) bool {
+
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	for _, allowed := range configStrings("upload_types", defaultUploadTypes) {
+		if allowed == "*" || allowed == mediaType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the path of an uploaded file
+// Files uploaded into a directory keep their name
+// @param target: string - The path given to upload, or empty for the current directory
+// @param name: string - The name of the attachment
+// @param channel: string - The channel used to resolve relative paths
+// @param count: int - The number of files uploaded at once
+// @return string - The path of the new file
+// @return error - The error if the name of the attachment is invalid or several files would be written to the same path
+func uploadPath(target string, name string, channel string, count int) (string, error) {
+
+	// The name of the attachment comes from the user, and is only used when no file name is given
+	if target == "" {
+		if err := checkName(name); err != nil {
+			return "", err
+		}
+		return name, nil
+	}
+
+	if _, err := getDirectory(target, true, channel); err == nil {
+		if err := checkName(name); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(target, "/") + "/" + name, nil
+	}
+
+	if count > 1 {
+		return "", errors.New("Error: Could not find the directory \"" + target + "\".")
+	}
+
+	return target, nil
+}
@@ -45,6 +45,35 @@ func getConfig(config *map[string]interface{}, path string) error {
 	return nil
 }
 
+// Get a number from the configuration file
+// @param name: string - The name of the configuration entry
+// @param fallback: int - The value used when the entry is missing
+// @return int - The configured number
+func configInt(name string, fallback int) int {
+	if value, ok := config[name].(float64); ok {
+		return int(value)
+	}
+	return fallback
+}
+
+// Get a list of strings from the configuration file
+// @param name: string - The name of the configuration entry
+// @param fallback: []string - The value used when the entry is missing
+// @return []string - The configured list
+func configStrings(name string, fallback []string) []string {
+	values, ok := config[name].([]interface{})
+	if !ok {
+		return fallback
+	}
+	list := []string{}
+	for _, value := range values {
+		if text, ok := value.(string); ok {
+			list = append(list, text)
+		}
+	}
+	return list
+}
+
 // Parse an incoming command and return the command and its arguments
 // @param message: string - The command to parse
 // @return []string - The command parsed
@@ -66,58 +95,40 @@ func parseCommandLine(input string) ([]string, error) {
 // @param message: string - The message to print
 // @param color: string - The color of the message
 func echo(session *discordgo.Session, channelId string, message string, color string) {
-
-	// Send the message to the discord channel
-	if len(message) > 1950 {
-		message = message[:1950] + "(...)"
-	}
-	_, err := session.ChannelMessageSend(channelId, "```"+color+message+"```")
-	if err != nil {
-		fmt.Println("Error: Could not send a response message,", err)
-	}
+	sendRendered(session, channelId, discordRenderer.renderMessage(color, message), color)
 }
 
-// Support for file and folder directory formatting
-// @param path: *string - The path to the directory
-// @return error - Any error that may have occurred
-func formatPath(path *string, channel string) error {
-
-	// Return to parent directory
-	if strings.Contains(*path, "..") {
-
-		currPath := getCurrentDirectoryPath(channel)
-
-		for _, dir := range strings.Split(*path, "/") {
-
-			if dir == ".." {
-				currDir, err := getDirectory(currPath, false, channel)
-
-				if err != nil {
-					return err
-				}
+// **DISCORD FEATURE ONLY**
+// Send a rendered command result to the discord channel
+// @param session: *discordgo.Session - The discord session to use
+// @param channelId: string - The channel to send the result to
+// @param rendered: Rendered - The rendered result
+// @param color: string - The color of the text, if not sent as an embed
+func sendRendered(session *discordgo.Session, channelId string, rendered Rendered, color string) {
+
+	// Send embeds as they are
+	if rendered.embed != nil {
+		queueMessage(session, channelId, &Outbound{"", color, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{rendered.embed}}, nil, nil})
+		return
+	}
 
-				currPath = (*currDir).path
+	// Split long messages into pages
+	pages := paginate(rendered.text, PAGE_SIZE)
+	if len(pages) > 1 {
+		sendPager(session, channelId, &Pager{pages, color, 0})
+		return
+	}
 
-			} else if dir != "" {
-				currPath += "/" + dir
-			}
-		}
+	// Send the message to the discord channel
+	queueMessage(session, channelId, &Outbound{rendered.text, color, nil, nil, nil})
+}
 
-		*path = currPath
-		return nil
-
-	} else if (*path)[0] == '~' {
-		// Return to root directory
-		*path = "/" + (*path)[1:]
-		return nil
-	} else if (*path)[0] == '.' {
-		// Return to current directory
-		*path = getCurrentDirectoryPath(channel) + "/" + (*path)[1:]
-		return nil
-	} else {
-		*path = getCurrentDirectoryPath(channel) + "/" + *path
-		return nil
-	}
+// **CLI FEATURE ONLY**
+// Print a message to the terminal
+// @param message: string - The message to print
+// @param color: string - The color of the message
+func cliEcho(message string, color string) {
+	fmt.Println(cliRenderer.renderMessage(color, message).text)
 }
 
 // Generate a new Discord channel name
@@ -130,3 +141,29 @@ func NewChannelName(size int) string {
 	}
 	return name
 }
+
+// Format a string as a JSON string literal
+// @param value: string - The string to format
+// @return string - The quoted and escaped string
+func jsonString(value string) string {
+	output, _ := json.Marshal(value)
+	return string(output)
+}
+
+// Remove a flag from a command line
+// @param commands: []string - The command line
+// @param flag: string - The flag to remove
+// @return []string - The command line without the flag
+// @return bool - True if the flag was found, false otherwise
+func popFlag(commands []string, flag string) ([]string, bool) {
+	output := make([]string, 0, len(commands))
+	found := false
+	for _, command := range commands {
+		if command == flag {
+			found = true
+		} else {
+			output = append(output, command)
+		}
+	}
+	return output, found
+}
@@ -16,18 +16,19 @@ type SystemVariable struct {
 
 // If the system variable exists, return it, otherwise return nil
 // @param name: string - The name of the system variable
+// @param env: *Environment - The environment holding the variable
 // @return *SystemVariable - The system variable
 // @return error - The error if the system variable does not exist
-func getSystemVariable(name string) (*SystemVariable, error) {
-	if systemVariableExists(name) {
+func getSystemVariable(name string, env *Environment) (*SystemVariable, error) {
+	if systemVariableExists(name, env) {
 
 		if name == "time" {
-			timeInt, _ := strconv.ParseInt(systemVariables["time"].Value, 10, 64)
+			timeInt, _ := strconv.ParseInt(env.variables["time"].Value, 10, 64)
 			// Format the int unix to a string date
 			return &SystemVariable{"time", false, time.Unix(time.Now().Unix()-timeInt, 0).Format("2006-01-02 15:04:05")}, nil
 		}
 
-		return systemVariables[name], nil
+		return env.variables[name], nil
 	} else {
 		return nil, errors.New("Error: The variable \"" + name + "\" does not exist.")
 	}
@@ -36,12 +37,13 @@ func getSystemVariable(name string) (*SystemVariable, error) {
 // Set a system variable to a specified value, if the variable exists
 // @param name: string - The name of the system variable to set
 // @param value: string - The value of the system variable
+// @param env: *Environment - The environment holding the variable
 // @return error - The error if the system variable does not exist or is immutable
-func setSystemVariable(name string, value string) error {
+func setSystemVariable(name string, value string, env *Environment) error {
 
 	// Check to see if the variable exists
 	// If it does, get the variable
-	variable, err := getSystemVariable(name)
+	variable, err := getSystemVariable(name, env)
 	if err != nil {
 		return err
 	}
@@ -65,7 +67,7 @@ func setSystemVariable(name string, value string) error {
 
 				deltaTime := time.Now().Unix() - newTime.Unix()
 
-				systemVariables["time"].Value = fmt.Sprint(deltaTime)
+				env.variables["time"].Value = fmt.Sprint(deltaTime)
 			}
 
 			return nil
@@ -83,38 +85,41 @@ func setSystemVariable(name string, value string) error {
 // Create a system variable if it does not exist
 // @param name: string - The name of the system variable to create
 // @param value: string - The value of the system variable
+// @param env: *Environment - The environment holding the variable
 // @return error - The error if the system variable already exists
-func createSystemVariable(name string, value string) error {
-	if systemVariableExists(name) {
+func createSystemVariable(name string, value string, env *Environment) error {
+	if systemVariableExists(name, env) {
 		return errors.New("Error: The variable \"" + name + "\" already exists.")
 	} else {
-		systemVariables[name] = &SystemVariable{name, false, value}
+		env.variables[name] = &SystemVariable{name, false, value}
 		return nil
 	}
 }
 
 // Determine if a system variable exists already
 // @param name: string - The name of the system variable
+// @param env: *Environment - The environment holding the variable
 // @return bool - True if the system variable exists, false otherwise
-func systemVariableExists(name string) bool {
-	_, ok := systemVariables[name]
+func systemVariableExists(name string, env *Environment) bool {
+	_, ok := env.variables[name]
 	return ok
 }
 
 // Delete a system variable if it exists
 // @param name: string - The name of the system variable to delete
+// @param env: *Environment - The environment holding the variable
 // @return error - The error if the system variable does not exist
-func deleteSystemVariable(name string) error {
-	if systemVariableExists(name) {
+func deleteSystemVariable(name string, env *Environment) error {
+	if systemVariableExists(name, env) {
 
 		// Check status of the variable
-		variable, _ := getSystemVariable(name)
+		variable, _ := getSystemVariable(name, env)
 		if (*variable).Immutable || (*variable).Name == "time" {
 			return errors.New("Error: Cannot delete the immutable or essential system variable \"" + name + "\".")
 		}
 
 		// Remove the system variable from the map
-		delete(systemVariables, name)
+		delete(env.variables, name)
 		return nil
 	} else {
 		return errors.New("Error: The variable \"" + name + "\" does not exist.")
@@ -76,7 +76,7 @@ func LockFolder(folder *Folder, keyString string) error {
 	return nil
 }
 
-func UnlockFile(file *File, keyString string) error {
+func UnlockFile(file *File, keyString string, context *HookContext) error {
 	if !file.locked {
 		return errors.New("Error: The file is not locked.")
 	}
@@ -86,11 +86,12 @@ func UnlockFile(file *File, keyString string) error {
 	}
 
 	file.locked = false
+	fireUnlockHooks(file.hooks, &file.unlockedOnce, context)
 
 	return nil
 }
 
-func UnlockFolder(folder *Folder, keyString string) error {
+func UnlockFolder(folder *Folder, keyString string, context *HookContext) error {
 	if !folder.locked {
 		return errors.New("Error: The folder is not locked.")
 	}
@@ -100,6 +101,7 @@ func UnlockFolder(folder *Folder, keyString string) error {
 	}
 
 	folder.locked = false
+	fireUnlockHooks(folder.hooks, &folder.unlockedOnce, context)
 
 	return nil
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/tidwall/gjson"
+)
+
+// Structure describing who is looking at the directory tree
+type Viewer struct {
+	session *discordgo.Session
+	member  *discordgo.Member
+	guildID string
+	channel string
+	showAll bool
+}
+
+// Determine if a file or folder shows up in a listing
+// Dotfiles and hidden elements only show up once revealed, or to viewers allowed to see everything
+// @param name: string - The name of the element
+// @param hidden: bool - Whether the element is hidden
+// @param revealWhen: string - The conditions revealing the element, as raw JSON
+// @param viewer: *Viewer - Who is looking at the element
+// @return bool - True if the element is visible, false otherwise
+func isVisible(name string, hidden bool, revealWhen string, viewer *Viewer) bool {
+
+	if viewer != nil && viewer.showAll {
+		return true
+	}
+
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+
+	if !hidden {
+		return true
+	}
+
+	return revealConditionsMet(revealWhen, viewer)
+}
+
+// Determine if the conditions revealing an element are all met
+// Supported conditions are "var" (variables holding a value), "role" (a role held by the viewer)
+// and "unlocked" (a path that is no longer locked)
+// @param revealWhen: string - The conditions, as raw JSON
+// @param viewer: *Viewer - Who is looking at the element
+// @return bool - True if every condition is met, false otherwise
+func revealConditionsMet(revealWhen string, viewer *Viewer) bool {
+
+	conditions := gjson.Parse(revealWhen)
+	checked := false
+
+	channel := ""
+	if viewer != nil {
+		channel = viewer.channel
+	}
+
+	// Variables holding a specific value
+	if conditions.Get("var").Exists() {
+		for name, value := range conditions.Get("var").Map() {
+			variable, err := getVariable(name, channel)
+			if err != nil || variable.Value != value.String() {
+				return false
+			}
+		}
+		checked = true
+	}
+
+	// Role held by the viewer
+	if conditions.Get("role").Exists() {
+		if viewer == nil || viewer.session == nil || viewer.member == nil {
+			return false
+		}
+		if !HasRole(viewer.session, viewer.member, viewer.guildID, conditions.Get("role").String()) {
+			return false
+		}
+		checked = true
+	}
+
+	// Another vault unlocked
+	if conditions.Get("unlocked").Exists() {
+		path := conditions.Get("unlocked").String()
+		if _, err := getFile(path, false, channel); err != nil {
+			folder, err := getDirectory(path, false, channel)
+			if err != nil || folder == nil {
+				return false
+			}
+		}
+		checked = true
+	}
+
+	// Elements only revealed by typing their path have no other condition
+	return checked
+}
+
+// Reveal an element accessed by its exact path, if it allows it
+// @param hidden: *bool - Whether the element is hidden
+// @param revealWhen: string - The conditions revealing the element, as raw JSON
+func revealIfTyped(hidden *bool, revealWhen string) {
+	if *hidden && gjson.Get(revealWhen, "typed").Bool() {
+		*hidden = false
+	}
+}